@@ -11,6 +11,8 @@ import (
 	"github.com/martini-contrib/strict"
 	"github.com/mvader/sunglasses/handlers"
 	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/modules/video"
 	"github.com/mvader/sunglasses/services"
 	"github.com/mvader/sunglasses/util"
 	"io/ioutil"
@@ -56,6 +58,15 @@ func NewApp(configPath string) (*App, error) {
 		return nil, err
 	}
 
+	// Create fetcher service
+	fetcher := services.NewFetcher(config)
+
+	// Create video provider registry
+	videos, err := video.NewRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create and setup logger
 	var logFile string
 	if strings.HasSuffix(config.LogsPath, "/") {
@@ -80,6 +91,12 @@ func NewApp(configPath string) (*App, error) {
 	// sunglasses ts as *TaskService
 	m.Map(ts)
 
+	// Map fetcher as *Fetcher
+	m.Map(fetcher)
+
+	// Map videos as *video.Registry
+	m.Map(videos)
+
 	// Map logger as *log.Logger
 	m.Map(logger)
 
@@ -113,6 +130,12 @@ func NewApp(configPath string) (*App, error) {
 	// Add routes
 	addRoutes(m)
 
+	// Delete expired posts and data exports in background
+	go jobs.RunExpirationSweeper(middleware.Context{Config: config, Conn: conn, Tasks: ts}, time.Minute)
+
+	// Publish scheduled posts in background
+	go jobs.RunPostScheduler(middleware.Context{Config: config, Conn: conn, Tasks: ts}, time.Minute)
+
 	// Add NotFound handler
 	m.Router.NotFound(strict.MethodNotAllowed, strict.NotFound)
 
@@ -127,8 +150,60 @@ func addRoutes(m *martini.ClassicMartini) {
 			r.Get("/show/:id", handlers.ShowPost)
 			r.Post("/create", handlers.CreatePost)
 			r.Delete("/destroy/:id", handlers.DeletePost)
+			r.Put("/edit/:id", handlers.EditPost)
+			r.Get("/revisions/:id", handlers.GetPostRevisions)
+			r.Get("/hashtag/:tag", handlers.GetHashtagPosts)
+			r.Get("/scheduled", handlers.ListScheduledPosts)
+			r.Put("/reschedule/:id", handlers.ReschedulePost)
 			r.Put("/like/:id", handlers.LikePost)
+			r.Put("/react/:id", handlers.ReactToPost)
+			r.Get("/likes/:id", handlers.ListPostLikes)
+			r.Put("/pin/:id", handlers.PinPost)
+			r.Put("/unpin/:id", handlers.UnpinPost)
+			r.Put("/vote/:id", handlers.VotePoll)
 			r.Put("/change_privacy/:id", handlers.ChangePostPrivacy)
+			r.Put("/change_privacy_all", handlers.ChangePostsPrivacy)
+		}, middleware.LoginRequired)
+
+		// Draft routes
+		r.Group("/drafts", func(r martini.Router) {
+			r.Post("/create", handlers.CreateDraft)
+			r.Get("/list", handlers.ListDrafts)
+			r.Get("/show/:id", handlers.ShowDraft)
+			r.Put("/update/:id", handlers.UpdateDraft)
+			r.Delete("/destroy/:id", handlers.DeleteDraft)
+			r.Post("/publish/:id", handlers.PublishDraft)
+		}, middleware.LoginRequired)
+
+		// Bookmark routes
+		r.Group("/bookmarks", func(r martini.Router) {
+			r.Post("/create", handlers.BookmarkPost)
+			r.Get("/list", handlers.ListBookmarks)
+			r.Get("/collections", handlers.ListBookmarkCollections)
+			r.Delete("/destroy/:post_id", handlers.RemoveBookmark)
+		}, middleware.LoginRequired)
+
+		// Album routes
+		r.Group("/albums", func(r martini.Router) {
+			r.Post("/create", handlers.CreateAlbum)
+			r.Get("/show/:id", handlers.ShowAlbum)
+			r.Get("/for_user/:user_id", handlers.GetUserAlbums)
+			r.Put("/update/:id", handlers.UpdateAlbum)
+			r.Delete("/destroy/:id", handlers.DeleteAlbum)
+			r.Put("/add_photo/:id", handlers.AddPhotoToAlbum)
+			r.Put("/remove_photo/:id", handlers.RemovePhotoFromAlbum)
+		}, middleware.LoginRequired)
+
+		// Report routes
+		r.Post("/reports/create", middleware.LoginRequired, handlers.CreateReport)
+		r.Group("/reports", func(r martini.Router) {
+			r.Get("/list", handlers.ListReports)
+			r.Put("/resolve/:id", handlers.ResolveReport)
+		}, middleware.LoginRequired, middleware.ModeratorRequired)
+
+		// Job routes
+		r.Group("/jobs", func(r martini.Router) {
+			r.Get("/show/:id", handlers.ShowJob)
 		}, middleware.LoginRequired)
 
 		// Auth routes
@@ -141,7 +216,11 @@ func addRoutes(m *martini.ClassicMartini) {
 		r.Group("/comments", func(r martini.Router) {
 			r.Post("/create", handlers.CreateComment)
 			r.Get("/for_post/:post_id", handlers.CommentsForPost)
+			r.Get("/replies/:comment_id", handlers.CommentReplies)
 			r.Delete("/destroy/:comment_id", handlers.RemoveComment)
+			r.Put("/react/:comment_id", handlers.ReactToComment)
+			r.Put("/edit/:comment_id", handlers.EditComment)
+			r.Get("/revisions/:comment_id", handlers.GetCommentRevisions)
 		}, middleware.LoginRequired)
 
 		// Account routes
@@ -153,6 +232,8 @@ func addRoutes(m *martini.ClassicMartini) {
 			r.Put("/update_picture", handlers.UpdateProfilePicture)
 			r.Put("/data", handlers.UpdateAccountData)
 			r.Put("/password", handlers.UpdateAccountPassword)
+			r.Post("/export", handlers.ExportAccountData)
+			r.Delete("/destroy", handlers.DestroyAccount)
 		}, middleware.WebOnly, middleware.LoginRequired)
 		r.Get("/account/username_taken", middleware.WebOnly, middleware.LoginForbidden, handlers.IsUsernameTaken)
 		r.Post("/account/signup", middleware.WebOnly, middleware.LoginForbidden, handlers.CreateAccount)
@@ -201,6 +282,9 @@ func addRoutes(m *martini.ClassicMartini) {
 	// Logout
 	m.Get("/account/logout", middleware.WebOnly, handlers.Logout)
 
+	// Download a data export
+	m.Get("/account/export/:id", middleware.WebOnly, middleware.LoginRequired, handlers.DownloadAccountData)
+
 	// Render the layout
 	m.Get("/", func(c middleware.Context) string {
 		var (
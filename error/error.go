@@ -33,6 +33,8 @@ const (
 	CodeInvalidInfoLength         = 33
 	CodeInvalidPrivacySettings    = 34
 	CodePasswordCurrentError      = 35
+	CodeAccountPendingDeletion    = 36
+	CodeAccountSuspended          = 37
 
 	// Post Codes [50-70]
 	CodeInvalidStatusText     = 50
@@ -46,6 +48,34 @@ const (
 	CodeInvalidLinkURL        = 58
 	CodeInvalidUserList       = 59
 	CodeInvalidCommentText    = 60
+	CodeInvalidExpiration     = 61
+	CodeTooManyPhotos         = 65
+	CodeCantPostOnProfile     = 66
+	CodeCantReshare           = 67
+	CodeInvalidPublishTime    = 68
+	CodeInvalidReaction       = 69
+	CodeTooManyPinnedPosts    = 70
+	CodeCantPinPost           = 71
+	CodeInvalidPoll           = 72
+	CodeInvalidPollDuration   = 73
+	CodePollClosed            = 74
+	CodeAlreadyVoted          = 75
+	CodeInvalidVote           = 76
+	CodeInvalidContentWarning = 77
+	CodeCantReply             = 78
+
+	// Report codes [80-89]
+	CodeInvalidReportReason = 80
+	CodeAlreadyReported     = 81
+	CodeInvalidReportAction = 82
+
+	// Album codes
+	CodeInvalidAlbumTitle       = 62
+	CodeInvalidAlbumDescription = 63
+	CodeInvalidAlbumPhoto       = 64
+
+	// Bookmark codes [90-99]
+	CodeInvalidCollectionName = 90
 
 	// Auth messages
 	MsgInvalidAccessToken        = "Invalid access token provided"
@@ -79,6 +109,8 @@ const (
 	MsgInvalidInfoLength         = "One or more of the provided fields is more than 500 characters long"
 	MsgInvalidPrivacySettings    = "Invalid privacy settings provided"
 	MsgPasswordCurrentError      = "Invalid current password provided"
+	MsgAccountPendingDeletion    = "The account has been deleted, log in again with restore set to true to restore it"
+	MsgAccountSuspended          = "The account has been suspended"
 
 	// Post messages
 	MsgInvalidStatusText     = "Status text must not be more than 1500 characters long"
@@ -92,4 +124,32 @@ const (
 	MsgInvalidLinkURL        = "Invalid link URL"
 	MsgInvalidUserList       = "Invalid user list provided"
 	MsgInvalidCommentText    = "Comment text must not be more than 500 characters long or be empty"
+	MsgInvalidExpiration     = "Invalid post expiration provided"
+	MsgTooManyPhotos         = "A post can not have more than 20 photos"
+	MsgCantPostOnProfile     = "You can't post on the profile of that user"
+	MsgCantReshare           = "The post can't be reshared with the given privacy settings"
+	MsgInvalidPublishTime    = "Invalid publish time provided"
+	MsgInvalidReaction       = "Invalid reaction provided"
+	MsgTooManyPinnedPosts    = "A profile can not have more than 3 pinned posts"
+	MsgCantPinPost           = "The post can't be pinned"
+	MsgInvalidPoll           = "A poll must have between 2 and 10 options not empty or more than 100 characters long"
+	MsgInvalidPollDuration   = "Invalid poll duration provided"
+	MsgPollClosed            = "The poll is already closed"
+	MsgAlreadyVoted          = "You have already voted in this poll"
+	MsgInvalidVote           = "Invalid poll options provided"
+	MsgInvalidContentWarning = "Content warning must not be more than 100 characters long"
+	MsgCantReply             = "The comment can't be replied"
+
+	// Report messages
+	MsgInvalidReportReason = "Invalid report reason provided"
+	MsgAlreadyReported     = "You have already reported this content"
+	MsgInvalidReportAction = "Invalid report action provided"
+
+	// Album messages
+	MsgInvalidAlbumTitle       = "Album title must not be empty or more than 100 characters long"
+	MsgInvalidAlbumDescription = "Album description must not be more than 1500 characters long"
+	MsgInvalidAlbumPhoto       = "Only your own photos can be added to an album"
+
+	// Bookmark messages
+	MsgInvalidCollectionName = "Collection name must not be more than 50 characters long"
 )
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"errors"
+	"github.com/go-martini/martini"
 	. "github.com/mvader/sunglasses/error"
 	"github.com/mvader/sunglasses/middleware"
 	"github.com/mvader/sunglasses/models"
-	"github.com/mvader/sunglasses/modules/timeline"
+	"github.com/mvader/sunglasses/modules/jobs"
 	"github.com/mvader/sunglasses/modules/upload"
 	"github.com/mvader/sunglasses/util"
 	"labix.org/v2/mgo/bson"
@@ -15,6 +16,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CreateAccount is a handler in charge of creating an user account. After a successful registration the user will be automatically logged in.
@@ -137,7 +139,7 @@ func IsUsernameTaken(c middleware.Context) {
 // GetAccountInfo retrieves the info of the user
 func GetAccountInfo(c middleware.Context) {
 	c.Success(200, map[string]interface{}{
-		"account_info": c.User.Info,
+		"account_info": c.User.InfoWithPrivacy(),
 	})
 }
 
@@ -214,6 +216,24 @@ func UpdateAccountInfo(c middleware.Context) {
 	info.TV = strings.TrimSpace(c.Form("tv"))
 	info.About = strings.TrimSpace(c.Form("about"))
 
+	// Every field can have its own privacy settings, only the ones sent will be updated
+	for _, field := range models.InfoFields {
+		if c.Form("privacy_"+field+"_type") == "" {
+			continue
+		}
+
+		p, err := getPrivacySettings(c, field)
+		if err != nil {
+			c.Error(400, CodeInvalidPrivacySettings, MsgInvalidPrivacySettings)
+			return
+		}
+
+		if info.Privacy == nil {
+			info.Privacy = make(map[string]models.PrivacySettings)
+		}
+		info.Privacy[field] = p
+	}
+
 	// Update the user info in the database
 	c.User.Info = info
 	if err := c.User.Save(c.Conn); err != nil {
@@ -304,53 +324,11 @@ func UpdateAccountPassword(c middleware.Context) {
 func UpdateAccountSettings(c middleware.Context) {
 	s := c.User.Settings
 
-	getPrivacy := func(r *http.Request, kind string) (models.PrivacySettings, error) {
-		p := models.PrivacySettings{}
-
-		// Get the privacy type for the current kind of privacy
-		if privacyType := c.Form("privacy_" + kind + "_type"); privacyType != "" {
-			pType, err := strconv.ParseInt(privacyType, 10, 8)
-			if !models.IsValidPrivacyType(models.PrivacyType(pType)) || err != nil {
-				return p, errors.New("invalid data provided")
-			}
-
-			p.Type = models.PrivacyType(pType)
-		} else {
-			return p, errors.New("privacy type is required")
-		}
-
-		// Get users for the current kind of privacy
-		if users, ok := r.Form["privacy_"+kind+"_users"]; ok {
-			uids := make([]bson.ObjectId, 0, len(users))
-			for _, u := range users {
-				if !bson.IsObjectIdHex(u) {
-					return p, errors.New("invalid data provided")
-				}
-				uids = append(uids, bson.ObjectIdHex(u))
-			}
-
-			// Check that you only selected users you follow or that follow you
-			count, err := c.Count("follows", bson.M{"user_from": c.User.ID, "user_to": bson.M{"$in": uids}})
-			if err != nil || count != len(p.Users) {
-				count2, err := c.Count("follows", bson.M{"user_to": c.User.ID, "user_from": bson.M{"$in": uids}})
-				if err != nil || count+count2 != len(uids) {
-					return p, errors.New("invalid user list provided")
-				}
-			}
-
-			p.Users = uids
-		} else if p.Type > models.PrivacyNone {
-			return p, errors.New("users param required for this privacy type")
-		}
-
-		return p, nil
-	}
-
 	// If override_default_privacy is true the privacy settings used will be just status for
 	// all privacy kinds
 	s.OverrideDefaultPrivacy = c.GetBoolean("override_default_privacy")
 	if s.OverrideDefaultPrivacy {
-		p, err := getPrivacy(c.Request, "status")
+		p, err := getPrivacySettings(c, "status")
 		if err != nil {
 			c.Error(400, CodeInvalidPrivacySettings, MsgInvalidPrivacySettings)
 			return
@@ -359,7 +337,7 @@ func UpdateAccountSettings(c middleware.Context) {
 		s.DefaultStatusPrivacy = p
 	} else {
 		for _, k := range []string{"status", "video", "photo", "link", "album"} {
-			p, err := getPrivacy(c.Request, k)
+			p, err := getPrivacySettings(c, k)
 			if err != nil {
 				c.Error(400, CodeInvalidPrivacySettings, MsgInvalidPrivacySettings)
 				return
@@ -394,9 +372,24 @@ func UpdateAccountSettings(c middleware.Context) {
 	s.NotifyNewCommentOthers = c.GetBoolean("notify_new_comment_others")
 	s.NotifyPostsInMyProfile = c.GetBoolean("notify_posts_in_my_profile")
 	s.NotifyLikes = c.GetBoolean("notify_likes")
+	s.MuteCommentLikes = c.GetBoolean("mute_comment_likes")
 	s.AllowPostsInMyProfile = c.GetBoolean("allow_posts_in_my_profile")
 	s.AllowCommentsInPosts = c.GetBoolean("allow_comments_in_posts")
 	s.DisplayInfoFollowersOnly = c.GetBoolean("display_info_followers_only")
+	s.ExpandSensitiveContent = c.GetBoolean("expand_sensitive_content")
+
+	// Default expiration for new posts in seconds, 0 means posts don't expire
+	if expiration := c.Form("default_post_expiration"); expiration != "" {
+		seconds, err := strconv.ParseInt(expiration, 10, 64)
+		if err != nil || seconds < 0 {
+			c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+			return
+		}
+
+		s.DefaultPostExpiration = seconds
+	} else {
+		s.DefaultPostExpiration = 0
+	}
 
 	// Recovery method settings. If no recovery_method value is sent or an invalid recovery_method
 	// is entered RecoveryNone will be set!
@@ -433,6 +426,50 @@ func UpdateAccountSettings(c middleware.Context) {
 	})
 }
 
+// getPrivacySettings returns the privacy settings sent in the privacy_KIND_type and privacy_KIND_users
+// form fields for the given kind
+func getPrivacySettings(c middleware.Context, kind string) (models.PrivacySettings, error) {
+	p := models.PrivacySettings{}
+
+	// Get the privacy type for the current kind of privacy
+	if privacyType := c.Form("privacy_" + kind + "_type"); privacyType != "" {
+		pType, err := strconv.ParseInt(privacyType, 10, 8)
+		if !models.IsValidPrivacyType(models.PrivacyType(pType)) || err != nil {
+			return p, errors.New("invalid data provided")
+		}
+
+		p.Type = models.PrivacyType(pType)
+	} else {
+		return p, errors.New("privacy type is required")
+	}
+
+	// Get users for the current kind of privacy
+	if users, ok := c.Request.Form["privacy_"+kind+"_users"]; ok {
+		uids := make([]bson.ObjectId, 0, len(users))
+		for _, u := range users {
+			if !bson.IsObjectIdHex(u) {
+				return p, errors.New("invalid data provided")
+			}
+			uids = append(uids, bson.ObjectIdHex(u))
+		}
+
+		// Check that you only selected users you follow or that follow you
+		count, err := c.Count("follows", bson.M{"user_from": c.User.ID, "user_to": bson.M{"$in": uids}})
+		if err != nil || count != len(uids) {
+			count2, err := c.Count("follows", bson.M{"user_to": c.User.ID, "user_from": bson.M{"$in": uids}})
+			if err != nil || count+count2 != len(uids) {
+				return p, errors.New("invalid user list provided")
+			}
+		}
+
+		p.Users = uids
+	} else if p.Type > models.PrivacyNone {
+		return p, errors.New("users param required for this privacy type")
+	}
+
+	return p, nil
+}
+
 // UpldateProfilePicture updates the current profile picture of the user
 func UpdateProfilePicture(c middleware.Context) {
 	// Try to retrieve the uploaded image
@@ -491,84 +528,95 @@ func UpdateProfilePicture(c middleware.Context) {
 	})
 }
 
-// DestroyAccount destroys the user account and all its related content such as comments, posts, images, etc.
+// DestroyAccount deactivates the user account. The account is hidden and all its tokens are revoked
+// immediately but its content is kept until the grace period passes, during which the user can restore
+// it logging in again. After that the account is purged by a background job.
 func DestroyAccount(c middleware.Context) {
-	// TODO: Log errors removing images and push image remove to tasks
 	confirmed := c.GetBoolean("confirmed")
 
 	if confirmed {
-		var (
-			p   models.Post
-			cmt models.Comment
-		)
-
-		// Destroy all user tokens
-		c.RemoveAll("tokens", bson.M{"user_id": c.User.ID})
-
-		// Destroy all user material (posts + comments + images)
-		iter := c.Find("posts", bson.M{"user_id": c.User.ID}).Iter()
-		for iter.Next(&p) {
-			if p.Type == models.PostPhoto {
-				os.Remove(upload.ToLocalImagePath(p.PhotoURL, c.Config))
-				os.Remove(upload.ToLocalThumbnailPath(p.Thumbnail, c.Config))
-			}
-
-			go timeline.PropagatePostsOnDeletion(c, p.ID)
-		}
-
-		iter.Close()
+		c.User.Active = false
+		c.User.Deleted = float64(time.Now().Unix())
 
-		// Destroy all user comments
-		iter = c.Find("comments", bson.M{"user_id": c.User.ID}).Iter()
-		for iter.Next(&cmt) {
-			go timeline.PropagatePostOnCommentDeleted(c, cmt.PostID, cmt.ID)
+		if err := c.User.Save(c.Conn); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
 		}
 
-		iter.Close()
-
-		// Remove other stuff related to the user
-		c.RemoveAll("posts", bson.M{"user_id": c.User.ID})
-		c.RemoveAll("comments", bson.M{"user_id": c.User.ID})
-		c.RemoveAll("follows", bson.M{"user_to": c.User.ID})
-		c.RemoveAll("follows", bson.M{"user_from": c.User.ID})
-		c.RemoveAll("blocks", bson.M{"user_to": c.User.ID})
-		c.RemoveAll("blocks", bson.M{"user_from": c.User.ID})
-		c.RemoveAll("timelines", bson.M{"user_id": c.User.ID})
-		c.RemoveAll("likes", bson.M{"user_id": c.User.ID})
-		go timeline.PropagatePostOnUserDeleted(c, c.User.ID)
+		// Destroy all user tokens
+		c.RemoveAll("tokens", bson.M{"user_id": c.User.ID})
 
 		// Logout user
 		c.Session.Values["user_token"] = nil
 		c.Session.Values["csrf_key"] = nil
 		c.Session.Save(c.Request, c.ResponseWriter)
 
-		// Destroy user
-		c.Remove("users", bson.M{"_id": c.User.ID})
+		c.Success(200, map[string]interface{}{
+			"message":       "User account has been successfully deleted",
+			"restore_until": c.User.Deleted + c.Config.AccountDeletionGracePeriod().Seconds(),
+		})
+	} else {
+		c.Success(200, map[string]interface{}{"message": "User account has not been destroyed"})
+	}
+}
 
-		// Destroy all user notifications
-		c.RemoveAll("notifications", bson.M{"user_id": c.User.ID})
+// ExportAccountData starts a job that generates an archive with all the user data. If there is already
+// an export running for the user that job will be returned instead.
+func ExportAccountData(c middleware.Context) {
+	var job models.Job
+
+	err := c.Find("jobs", bson.M{"user_id": c.User.ID, "job_type": models.JobDataExport, "status": models.JobRunning}).One(&job)
+	if err == nil {
+		c.Success(200, map[string]interface{}{
+			"message": "Data export is already in progress",
+			"job":     job,
+		})
+		return
+	}
 
-		// Remove user avatars
-		if c.User.Avatar != "" {
-			os.Remove(upload.ToLocalImagePath(c.User.Avatar, c.Config))
-		}
+	export := models.NewJob(models.JobDataExport, c.User.ID)
+	if err := export.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
 
-		if c.User.AvatarThumbnail != "" {
-			os.Remove(upload.ToLocalThumbnailPath(c.User.AvatarThumbnail, c.Config))
-		}
+	// The job is modified while it runs, so the response has a copy of it
+	started := *export
+	go jobs.ExportUserData(c, export, c.User)
 
-		if c.User.PublicAvatar != "" {
-			os.Remove(upload.ToLocalImagePath(c.User.PublicAvatar, c.Config))
-		}
+	c.Success(202, map[string]interface{}{
+		"message": "Data export started",
+		"job":     started,
+	})
+}
 
-		if c.User.PublicAvatarThumbnail != "" {
-			os.Remove(upload.ToLocalThumbnailPath(c.User.PublicAvatarThumbnail, c.Config))
-		}
+// DownloadAccountData sends the archive generated by a data export job as long as it has not expired
+func DownloadAccountData(c middleware.Context, params martini.Params) {
+	var job models.Job
 
-		c.Success(200, map[string]interface{}{"message": "User account has been successfully destroyed"})
-	} else {
-		c.Success(200, map[string]interface{}{"message": "User account has not been destroyed"})
+	jobID := params["id"]
+	if !bson.IsObjectIdHex(jobID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("jobs", bson.ObjectIdHex(jobID)).One(&job); err != nil || job.Type != models.JobDataExport {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
 	}
+
+	if job.UserID.Hex() != c.User.ID.Hex() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	if job.File == "" || job.Expired() {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	c.ResponseWriter.Header().Set("Content-Disposition", "attachment; filename=sunglasses-"+c.User.Username+".zip")
+	http.ServeFile(c.ResponseWriter, c.Request, job.File)
 }
 
 // ChangeLanguage changes the default language for the user
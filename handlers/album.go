@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"github.com/mvader/sunglasses/util"
+	"labix.org/v2/mgo/bson"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CreateAlbum creates a new photo album
+//
+// This handler needs the following parameters:
+// - title: Title of the album
+// - description: Description of the album (optional)
+// - privacy_type and privacy_users: Privacy of the album, the default album privacy of the user is used if not given
+func CreateAlbum(c middleware.Context) {
+	a := models.NewAlbum(c.User)
+	a.Title = strings.TrimSpace(c.Form("title"))
+	a.Description = strings.TrimSpace(c.Form("description"))
+
+	if a.Title == "" || util.Strlen(a.Title) > 100 {
+		c.Error(400, CodeInvalidAlbumTitle, MsgInvalidAlbumTitle)
+		return
+	}
+
+	if util.Strlen(a.Description) > 1500 {
+		c.Error(400, CodeInvalidAlbumDescription, MsgInvalidAlbumDescription)
+		return
+	}
+
+	privacy, err := getPostPrivacy(models.Album, c)
+	if err != nil {
+		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
+		return
+	}
+
+	a.Privacy = privacy
+
+	if err := a.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(201, map[string]interface{}{
+		"message": "Album created successfully",
+		"album":   *a,
+	})
+}
+
+// ShowAlbum returns the data of an album and up to 25 of its photos
+func ShowAlbum(c middleware.Context, params martini.Params) {
+	album, ok := findAlbum(c, params["id"], false)
+	if !ok {
+		return
+	}
+
+	olderThan, err := strconv.ParseInt(c.Form("older_than"), 10, 64)
+	if err != nil || olderThan <= 0 {
+		olderThan = time.Now().Unix() + 1
+	}
+
+	var (
+		photos = make([]models.Post, 0, 25)
+		ids    = make([]bson.ObjectId, 0, 25)
+		p      models.Post
+	)
+
+	iter := c.Find("posts", bson.M{"album_id": album.ID, "created": bson.M{"$lt": olderThan}}).Sort("-created").Iter()
+	for len(photos) < 25 && iter.Next(&p) {
+		if (&p).CanBeAccessedBy(c.User, c.Conn) {
+			photos = append(photos, p)
+			ids = append(ids, p.ID)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	udata := models.GetUsersData([]bson.ObjectId{album.UserID}, c.User, c.Conn)
+	user, ok := udata[album.UserID]
+	if !ok {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	reactions := models.GetReactionsForPosts(ids, c.User.ID, c.Conn)
+	for i, v := range photos {
+		photos[i].User = user
+		photos[i].Reaction = reactions[v.ID]
+	}
+
+	models.SetCollapsed(photos, c.User)
+
+	album.User = user
+	album.LoadDisplayData(c.User, c.Conn)
+
+	c.Success(200, map[string]interface{}{
+		"album":  album,
+		"photos": photos,
+		"count":  len(photos),
+	})
+}
+
+// GetUserAlbums retrieves the list of albums of an user that can be accessed by the user making the request
+func GetUserAlbums(c middleware.Context, params martini.Params) {
+	var a models.PhotoAlbum
+
+	userID := params["user_id"]
+	if !bson.IsObjectIdHex(userID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if models.UserExists(c.Conn, bson.ObjectIdHex(userID)) == nil {
+		c.Error(404, CodeUserDoesNotExist, MsgUserDoesNotExist)
+		return
+	}
+
+	count, offset := c.ListCountParams()
+	albums := make([]models.PhotoAlbum, 0, count)
+	skipped := 0
+
+	iter := c.Find("albums", bson.M{"user_id": bson.ObjectIdHex(userID)}).Sort("-updated").Iter()
+	for len(albums) < count && iter.Next(&a) {
+		if (&a).CanBeAccessedBy(c.User, c.Conn) {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			(&a).LoadDisplayData(c.User, c.Conn)
+			albums = append(albums, a)
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"albums": albums,
+		"count":  len(albums),
+	})
+}
+
+// UpdateAlbum updates the title, description, cover photo and privacy of an album owned by the user.
+// All the parameters are optional and the ones not given are left unchanged. When the privacy of the
+// album changes the timelines containing its photos are updated accordingly.
+func UpdateAlbum(c middleware.Context, params martini.Params) {
+	album, ok := findAlbum(c, params["id"], true)
+	if !ok {
+		return
+	}
+
+	if title := strings.TrimSpace(c.Form("title")); title != "" {
+		if util.Strlen(title) > 100 {
+			c.Error(400, CodeInvalidAlbumTitle, MsgInvalidAlbumTitle)
+			return
+		}
+
+		album.Title = title
+	}
+
+	if _, ok := c.Request.Form["description"]; ok {
+		album.Description = strings.TrimSpace(c.Form("description"))
+		if util.Strlen(album.Description) > 1500 {
+			c.Error(400, CodeInvalidAlbumDescription, MsgInvalidAlbumDescription)
+			return
+		}
+	}
+
+	if coverID := c.Form("cover_id"); coverID != "" {
+		if !bson.IsObjectIdHex(coverID) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+
+		count, err := c.Count("posts", bson.M{"_id": bson.ObjectIdHex(coverID), "album_id": album.ID})
+		if err != nil || count == 0 {
+			c.Error(400, CodeInvalidAlbumPhoto, MsgInvalidAlbumPhoto)
+			return
+		}
+
+		album.CoverID = bson.ObjectIdHex(coverID)
+	}
+
+	privacyChanged := false
+	if c.Form("privacy_type") != "" {
+		privacy, err := getPostPrivacy(models.Album, c)
+		if err != nil {
+			c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
+			return
+		}
+
+		album.Privacy = privacy
+		privacyChanged = true
+	}
+
+	album.Updated = float64(time.Now().Unix())
+	if err := album.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if privacyChanged {
+		if photos, err := albumPhotos(c, album.ID); err == nil {
+			jobs.RemoveInvalidReshares(c, photos...)
+			jobs.UnpinHiddenPosts(c, photos...)
+			go timeline.PropagatePostsOnAlbumChange(c, album.ID, photos)
+		}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"message": "Album updated successfully",
+		"album":   album,
+	})
+}
+
+// DeleteAlbum deletes an album owned by the user. The photos inside it are not deleted, only removed from the album.
+func DeleteAlbum(c middleware.Context, params martini.Params) {
+	album, ok := findAlbum(c, params["id"], true)
+	if !ok {
+		return
+	}
+
+	photos, err := albumPhotos(c, album.ID)
+	if err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if _, err := c.Query("posts").UpdateAll(bson.M{"album_id": album.ID}, bson.M{"$unset": bson.M{"album_id": ""}}); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if err := album.Remove(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	go timeline.PropagatePostsOnAlbumChange(c, album.ID, photos)
+
+	c.Success(200, map[string]interface{}{
+		"deleted": true,
+		"message": "Album deleted successfully",
+	})
+}
+
+// AddPhotoToAlbum adds a photo post of the user to an album owned by the user. If the photo was already
+// inside another album it is moved. The first photo added to an album becomes its cover.
+//
+// This handler needs the following parameters:
+// - post_id: ID of the photo post
+func AddPhotoToAlbum(c middleware.Context, params martini.Params) {
+	album, ok := findAlbum(c, params["id"], true)
+	if !ok {
+		return
+	}
+
+	post, ok := findAlbumPhoto(c)
+	if !ok {
+		return
+	}
+
+	post.AlbumID = album.ID
+	if err := post.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	album.Updated = float64(time.Now().Unix())
+	if album.CoverID.Hex() == "" {
+		album.CoverID = post.ID
+	}
+
+	if err := album.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	jobs.RemoveInvalidReshares(c, post.ID)
+	jobs.UnpinHiddenPosts(c, post.ID)
+	go timeline.PropagatePostsOnAlbumChange(c, album.ID, []bson.ObjectId{post.ID})
+
+	c.Success(200, map[string]interface{}{
+		"message": "Photo added to album successfully",
+	})
+}
+
+// RemovePhotoFromAlbum removes a photo post from an album owned by the user. The photo is not deleted.
+//
+// This handler needs the following parameters:
+// - post_id: ID of the photo post
+func RemovePhotoFromAlbum(c middleware.Context, params martini.Params) {
+	album, ok := findAlbum(c, params["id"], true)
+	if !ok {
+		return
+	}
+
+	post, ok := findAlbumPhoto(c)
+	if !ok {
+		return
+	}
+
+	if post.AlbumID != album.ID {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	post.AlbumID = ""
+	if err := post.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	album.Updated = float64(time.Now().Unix())
+	if album.CoverID == post.ID {
+		album.CoverID = ""
+	}
+
+	if err := album.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	go timeline.PropagatePostsOnAlbumChange(c, album.ID, []bson.ObjectId{post.ID})
+
+	c.Success(200, map[string]interface{}{
+		"message": "Photo removed from album successfully",
+	})
+}
+
+// findAlbum retrieves the album with the given id writing the error response if it does not exist
+// or the user can't access it. If ownerOnly is true only the owner of the album can access it.
+func findAlbum(c middleware.Context, albumID string, ownerOnly bool) (*models.PhotoAlbum, bool) {
+	album := new(models.PhotoAlbum)
+
+	if !bson.IsObjectIdHex(albumID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil, false
+	}
+
+	if err := c.FindId("albums", bson.ObjectIdHex(albumID)).One(album); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil, false
+	}
+
+	if (ownerOnly && album.UserID.Hex() != c.User.ID.Hex()) || !album.CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return nil, false
+	}
+
+	return album, true
+}
+
+// findAlbumPhoto retrieves the photo post given in the post_id parameter writing the error response
+// if it does not exist or it is not a photo owned by the user
+func findAlbumPhoto(c middleware.Context) (*models.Post, bool) {
+	post := new(models.Post)
+
+	postID := c.Form("post_id")
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil, false
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil, false
+	}
+
+	if post.UserID.Hex() != c.User.ID.Hex() || post.Type != models.PostPhoto {
+		c.Error(400, CodeInvalidAlbumPhoto, MsgInvalidAlbumPhoto)
+		return nil, false
+	}
+
+	return post, true
+}
+
+// albumPhotos returns the ids of all the photos inside the given album
+func albumPhotos(c middleware.Context, albumID bson.ObjectId) ([]bson.ObjectId, error) {
+	var posts []models.Post
+
+	if err := c.Find("posts", bson.M{"album_id": albumID}).Select(bson.M{"_id": 1}).All(&posts); err != nil {
+		return nil, err
+	}
+
+	ids := make([]bson.ObjectId, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+
+	return ids, nil
+}
@@ -49,7 +49,34 @@ func GetUserToken(c middleware.Context) {
 		return
 	}
 
-	if user.CheckPassword(password) && user.Active {
+	if !user.CheckPassword(password) {
+		c.Error(400, CodeInvalidUsernameOrPassword, MsgInvalidUsernameOrPassword)
+		return
+	}
+
+	// Suspended accounts can't log in nor be restored
+	if user.Suspended {
+		c.Error(403, CodeAccountSuspended, MsgAccountSuspended)
+		return
+	}
+
+	// Accounts deleted during the grace period can be restored logging in
+	if !user.Active && user.Deleted > 0 &&
+		user.Deleted+c.Config.AccountDeletionGracePeriod().Seconds() > float64(time.Now().Unix()) {
+		if !c.GetBoolean("restore") {
+			c.Error(403, CodeAccountPendingDeletion, MsgAccountPendingDeletion)
+			return
+		}
+
+		user.Active = true
+		user.Deleted = 0
+		if err := user.Save(c.Conn); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+	}
+
+	if user.Active {
 		token := new(models.Token)
 		token.Hash = util.NewRandomHash()
 		token.Expires = float64(time.Now().AddDate(0, 0, models.UserTokenExpirationDays).Unix())
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/util"
+	"labix.org/v2/mgo/bson"
+	"strings"
+)
+
+// BookmarkPost saves a post the user can access in the user's bookmarks. If the post was already saved
+// it is moved to the given collection.
+//
+// The following parameters are required:
+// - post_id: ID of the post
+//
+// The following parameters are optional:
+// - collection: Name of the collection the post is saved into
+func BookmarkPost(c middleware.Context) {
+	var bookmark models.Bookmark
+
+	collection := strings.TrimSpace(c.Form("collection"))
+	if util.Strlen(collection) > models.MaxCollectionNameLength {
+		c.Error(400, CodeInvalidCollectionName, MsgInvalidCollectionName)
+		return
+	}
+
+	post, ok := findAccessiblePost(c, c.Form("post_id"))
+	if !ok {
+		return
+	}
+
+	status := 200
+	if err := c.Find("bookmarks", bson.M{"user_id": c.User.ID, "post_id": post.ID}).One(&bookmark); err != nil {
+		bookmark = *models.NewBookmark(c.User.ID, post.ID, collection)
+		status = 201
+	}
+
+	bookmark.Collection = collection
+	if err := (&bookmark).Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(status, map[string]interface{}{
+		"message":  "Post saved successfully",
+		"bookmark": bookmark,
+	})
+}
+
+// RemoveBookmark removes a post from the user's bookmarks
+func RemoveBookmark(c middleware.Context, params martini.Params) {
+	postID := params["post_id"]
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.Remove("bookmarks", bson.M{"user_id": c.User.ID, "post_id": bson.ObjectIdHex(postID)}); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"deleted": true,
+		"message": "Bookmark deleted successfully",
+	})
+}
+
+// ListBookmarks retrieves the bookmarks of the user, the most recent first. Posts that have been deleted or
+// that the user can't access anymore are not listed.
+//
+// The following parameters are optional:
+// - collection: Only list the bookmarks in the given collection
+func ListBookmarks(c middleware.Context) {
+	var (
+		bookmarks []models.Bookmark
+		skipped   int
+	)
+
+	count, offset := c.ListCountParams()
+	query := bson.M{"user_id": c.User.ID}
+	if _, ok := c.Request.Form["collection"]; ok {
+		query["collection"] = strings.TrimSpace(c.Form("collection"))
+		if query["collection"] == "" {
+			query["collection"] = bson.M{"$exists": false}
+		}
+	}
+
+	// The privacy of the posts may have changed since they were saved, so the bookmarks are retrieved
+	// in batches until there are enough accessible ones to fill the page
+	result := make([]models.Bookmark, 0, count)
+	for from := 0; len(result) < count; from += count {
+		bookmarks = nil
+		if err := c.Find("bookmarks", query).Sort("-created").Skip(from).Limit(count).All(&bookmarks); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+
+		posts, err := accessibleBookmarkedPosts(c, bookmarks)
+		if err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+
+		for _, b := range bookmarks {
+			p, ok := posts[b.PostID]
+			if !ok {
+				continue
+			}
+
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			if len(result) < count {
+				b.Post = p
+				result = append(result, b)
+			}
+		}
+
+		if len(bookmarks) < count {
+			break
+		}
+	}
+
+	posts := make([]models.Post, 0, len(result))
+	ids := make([]bson.ObjectId, 0, len(result))
+	for _, b := range result {
+		posts = append(posts, *b.Post)
+		ids = append(ids, b.PostID)
+	}
+
+	reactions := models.GetReactionsForPosts(ids, c.User.ID, c.Conn)
+	for i := range posts {
+		posts[i].Reaction = reactions[posts[i].ID]
+	}
+
+	models.SetPostTargets(posts, c.User, c.Conn)
+	models.SetReshareOriginals(posts, c.User, c.Conn)
+	models.SetPollVotes(posts, c.User, c.Conn)
+	models.SetCollapsed(posts, c.User)
+
+	for i := range result {
+		result[i].Post = &posts[i]
+	}
+
+	c.Success(200, map[string]interface{}{
+		"bookmarks": result,
+		"count":     len(result),
+	})
+}
+
+// accessibleBookmarkedPosts returns the posts of the given bookmarks the user can still access along with
+// the data of their authors
+func accessibleBookmarkedPosts(c middleware.Context, bookmarks []models.Bookmark) (map[bson.ObjectId]*models.Post, error) {
+	var posts []models.Post
+
+	ids := make([]bson.ObjectId, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		ids = append(ids, b.PostID)
+	}
+
+	if err := c.Find("posts", bson.M{"_id": bson.M{"$in": ids}}).All(&posts); err != nil {
+		return nil, err
+	}
+
+	users := make([]bson.ObjectId, 0, len(posts))
+	for _, p := range posts {
+		users = append(users, p.UserID)
+	}
+
+	udata := models.GetUsersData(users, c.User, c.Conn)
+
+	accessible := make(map[bson.ObjectId]*models.Post)
+	for i := range posts {
+		u, ok := udata[posts[i].UserID]
+		if !ok || !(&posts[i]).CanBeAccessedBy(c.User, c.Conn) {
+			continue
+		}
+
+		posts[i].User = u
+		accessible[posts[i].ID] = &posts[i]
+	}
+
+	return accessible, nil
+}
+
+// ListBookmarkCollections retrieves the names of the collections the user has saved posts into
+func ListBookmarkCollections(c middleware.Context) {
+	var collections []string
+
+	if err := c.Find("bookmarks", bson.M{"user_id": c.User.ID, "collection": bson.M{"$exists": true}}).Distinct("collection", &collections); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if collections == nil {
+		collections = []string{}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"collections": collections,
+		"count":       len(collections),
+	})
+}
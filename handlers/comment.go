@@ -5,15 +5,23 @@ import (
 	. "github.com/mvader/sunglasses/error"
 	"github.com/mvader/sunglasses/middleware"
 	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
 	"github.com/mvader/sunglasses/modules/timeline"
 	"github.com/mvader/sunglasses/util"
 	"labix.org/v2/mgo/bson"
 	"strconv"
+	"time"
 )
 
 // CreateComment adds a comment to a post
+//
+// The following parameters are optional:
+// - parent_id: ID of the comment of the same post the comment replies to
 func CreateComment(c middleware.Context) {
-	var post models.Post
+	var (
+		post   models.Post
+		parent models.Comment
+	)
 
 	postID := c.Form("post_id")
 	if !bson.IsObjectIdHex(postID) {
@@ -38,17 +46,43 @@ func CreateComment(c middleware.Context) {
 	}
 
 	comment := models.NewComment(c.User.ID, post.ID)
+	if parentID := c.Form("parent_id"); parentID != "" {
+		if !bson.IsObjectIdHex(parentID) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+
+		if err := c.FindId("comments", bson.ObjectIdHex(parentID)).One(&parent); err != nil || parent.PostID.Hex() != post.ID.Hex() {
+			c.Error(404, CodeNotFound, MsgNotFound)
+			return
+		}
+
+		if !(&parent).CanBeReplied() || !(&parent).VisibleTo(c.User) {
+			c.Error(403, CodeCantReply, MsgCantReply)
+			return
+		}
+
+		comment = models.NewReply(c.User.ID, &parent)
+	}
+
 	comment.Message = message
+	comment.SetEntities(c.Conn)
 
 	if err := comment.Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
 
+	jobs.NotifyMentions(c, &post, comment, nil)
+
 	post.CommentsNum++
 	(&post).Save(c.Conn)
 
-	if post.UserID.Hex() != c.User.ID.Hex() {
+	// Replies are only displayed under their parent comment and notified to its author
+	if comment.ParentID.Hex() != "" {
+		c.Query("comments").UpdateId(parent.ID, bson.M{"$inc": bson.M{"replies": 1}})
+		jobs.NotifyReply(c, &parent)
+	} else if post.UserID.Hex() != c.User.ID.Hex() {
 		var n models.Notification
 		c.Find("notifications", bson.M{"notification_type": models.NotificationPostCommented, "post_id": post.ID, "user_id": post.UserID}).One(&n)
 		if n.ID.Hex() == "" {
@@ -65,10 +99,12 @@ func CreateComment(c middleware.Context) {
 		}
 	}
 
-	go timeline.PropagatePostOnNewComment(c, post.ID, comment.ID)
+	if comment.ParentID.Hex() == "" {
+		go timeline.PropagatePostOnNewComment(c, post.ID, comment.ID)
+	}
 
 	// Append user
-	comment.User = models.UserForDisplay(*c.User, true, false)
+	comment.User = models.UserForDisplay(*c.User, c.User.ID, false, false, false)
 
 	c.Success(201, map[string]interface{}{
 		"created": true,
@@ -77,7 +113,7 @@ func CreateComment(c middleware.Context) {
 	})
 }
 
-// DeleteComment removes a comment from a post
+// DeleteComment removes a comment from a post. Comments with replies are kept as a deleted placeholder.
 func RemoveComment(c middleware.Context, params martini.Params) {
 	var (
 		post    models.Post
@@ -90,7 +126,7 @@ func RemoveComment(c middleware.Context, params martini.Params) {
 		return
 	}
 
-	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil {
+	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil || comment.Deleted {
 		c.Error(404, CodeNotFound, MsgNotFound)
 		return
 	}
@@ -105,7 +141,7 @@ func RemoveComment(c middleware.Context, params martini.Params) {
 		return
 	}
 
-	if err := c.Query("comments").RemoveId(comment.ID); err != nil {
+	if _, err := jobs.RemoveComment(c, &comment); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
@@ -114,7 +150,9 @@ func RemoveComment(c middleware.Context, params martini.Params) {
 		post.CommentsNum--
 		(&post).Save(c.Conn)
 
-		go timeline.PropagatePostOnCommentDeleted(c, post.ID, comment.ID)
+		if comment.ParentID.Hex() == "" {
+			go timeline.PropagatePostOnCommentDeleted(c, post.ID, comment.ID)
+		}
 
 		c.Success(200, map[string]interface{}{
 			"deleted": true,
@@ -128,7 +166,129 @@ func RemoveComment(c middleware.Context, params martini.Params) {
 	}
 }
 
-// CommentsForPost returns a list with the comments for a post
+// EditComment edits the message of a comment owned by the user making the request in a post the user can
+// still access. The previous message of the comment is kept as a revision.
+//
+// The following parameters are required:
+// - comment_text: New message of the comment
+func EditComment(c middleware.Context, params martini.Params) {
+	var (
+		post    models.Post
+		comment models.Comment
+	)
+
+	commentID := params["comment_id"]
+	if !bson.IsObjectIdHex(commentID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil || comment.Deleted {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	// Hidden comments can't be edited until they are reviewed so the reported message is kept
+	if c.User.ID.Hex() != comment.UserID.Hex() || comment.Hidden {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	if err := c.FindId("posts", comment.PostID).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if !(&post).CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	message := c.Form("comment_text")
+	if util.Strlen(message) < 1 || util.Strlen(message) > 500 {
+		c.Error(400, CodeInvalidCommentText, MsgInvalidCommentText)
+		return
+	}
+
+	if message == comment.Message {
+		c.Success(200, map[string]interface{}{
+			"message": "Comment was not modified",
+			"comment": comment,
+		})
+		return
+	}
+
+	revision := models.NewCommentRevision(&comment, float64(time.Now().Unix()))
+	if err := revision.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	previousMentions := comment.Mentions
+	comment.Message = message
+	comment.Edited = true
+	comment.EditedAt = revision.Replaced
+	(&comment).SetEntities(c.Conn)
+
+	if err := (&comment).Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	jobs.NotifyMentions(c, &post, &comment, previousMentions)
+
+	comment.User = models.UserForDisplay(*c.User, c.User.ID, false, false, false)
+
+	c.Success(200, map[string]interface{}{
+		"message": "Comment edited successfully",
+		"comment": comment,
+	})
+}
+
+// GetCommentRevisions retrieves the previous revisions of a comment, newest first
+func GetCommentRevisions(c middleware.Context, params martini.Params) {
+	var (
+		post    models.Post
+		comment models.Comment
+	)
+
+	commentID := params["comment_id"]
+	if !bson.IsObjectIdHex(commentID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil || !comment.VisibleTo(c.User) {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if err := c.FindId("posts", comment.PostID).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if !post.CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	count, offset := c.ListCountParams()
+	revisions := make([]models.CommentRevision, 0, count)
+
+	if err := c.Find("comment_revisions", bson.M{"comment_id": comment.ID}).Sort("-replaced").Skip(offset).Limit(count).All(&revisions); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"revisions": revisions,
+		"count":     len(revisions),
+	})
+}
+
+// CommentsForPost returns a list with the top-level comments for a post, their replies are retrieved
+// with CommentReplies
 func CommentsForPost(c middleware.Context, params martini.Params) {
 	var (
 		post   models.Post
@@ -157,7 +317,11 @@ func CommentsForPost(c middleware.Context, params martini.Params) {
 	}
 
 	comments := make([]models.Comment, 0, 25)
-	cursor := c.Find("comments", bson.M{"post_id": post.ID, "created": bson.M{"$gt": olderThan}}).Sort("created").Limit(25).Iter()
+	cursor := c.Find("comments", bson.M{
+		"post_id":   post.ID,
+		"parent_id": bson.M{"$exists": false},
+		"created":   bson.M{"$gt": olderThan},
+	}).Sort("created").Limit(25).Iter()
 	for cursor.Next(&result) {
 		comments = append(comments, result)
 	}
@@ -167,6 +331,69 @@ func CommentsForPost(c middleware.Context, params martini.Params) {
 		return
 	}
 
+	commentsResult, ok := commentsForDisplay(c, comments)
+	if !ok {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"comments": commentsResult,
+		"count":    len(commentsResult),
+	})
+}
+
+// CommentReplies returns a list with the replies to a comment, the oldest first
+func CommentReplies(c middleware.Context, params martini.Params) {
+	var (
+		post    models.Post
+		comment models.Comment
+		replies []models.Comment
+	)
+
+	commentID := params["comment_id"]
+	if !bson.IsObjectIdHex(commentID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if err := c.FindId("posts", comment.PostID).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if !(&post).CanBeAccessedBy(c.User, c.Conn) || !(&comment).VisibleTo(c.User) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	count, offset := c.ListCountParams()
+	if err := c.Find("comments", bson.M{"parent_id": comment.ID}).Sort("created").Skip(offset).Limit(count).All(&replies); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	result, ok := commentsForDisplay(c, replies)
+	if !ok {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"comments": result,
+		"count":    len(result),
+	})
+}
+
+// commentsForDisplay adds the author and the reaction of the user to the given comments. Comments of
+// deleted users and hidden comments of other users are not returned, deleted placeholders are returned
+// without author.
+func commentsForDisplay(c middleware.Context, comments []models.Comment) ([]models.Comment, bool) {
 	users := make([]bson.ObjectId, 0, len(comments))
 	for _, c := range comments {
 		if c.UserID.Hex() != "" {
@@ -176,20 +403,27 @@ func CommentsForPost(c middleware.Context, params martini.Params) {
 
 	usersData := models.GetUsersData(users, c.User, c.Conn)
 	if usersData == nil {
-		c.Error(500, CodeUnexpected, MsgUnexpected)
-		return
+		return nil, false
 	}
 
-	commentsResult := make([]models.Comment, 0, len(comments))
+	result := make([]models.Comment, 0, len(comments))
 	for i, _ := range comments {
+		if !comments[i].VisibleTo(c.User) {
+			continue
+		}
+
+		if comments[i].Deleted {
+			result = append(result, comments[i])
+			continue
+		}
+
 		if v, ok := usersData[comments[i].UserID]; ok {
 			comments[i].User = v
-			commentsResult = append(commentsResult, comments[i])
+			result = append(result, comments[i])
 		}
 	}
 
-	c.Success(200, map[string]interface{}{
-		"comments": commentsResult,
-		"count":    len(commentsResult),
-	})
+	models.SetCommentReactions(result, c.User.ID, c.Conn)
+
+	return result, true
 }
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/modules/upload"
+	"github.com/mvader/sunglasses/util"
+	"labix.org/v2/mgo/bson"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// postTypeNames are the values of the post_type parameter for each post type
+var postTypeNames = map[models.ObjectType]string{
+	models.PostStatus:  "status",
+	models.PostPhoto:   "photo",
+	models.PostVideo:   "video",
+	models.PostLink:    "link",
+	models.PostReshare: "reshare",
+	models.PostPoll:    "poll",
+}
+
+// CreateDraft saves the content of a post that is not going to be published yet. Drafts are not validated
+// like posts are, they can be incomplete until they are published with PublishDraft.
+//
+// The following parameters are optional:
+// - post_type: Type of the post, status by default. It can't be changed later
+// - post_text, post_url, post_id, album_id, target_user_id, privacy_type, privacy_users: Same as in CreatePost
+// - content_warning, sensitive: Same as in CreatePost
+// - post_picture: Images of the post, only for photo drafts
+// - photo_captions: Captions of the photos in order, only for photo drafts
+// - poll_options, poll_multiple, poll_duration: Same as in CreatePost, only for poll drafts
+func CreateDraft(c middleware.Context) {
+	postType := models.ObjectType(models.PostStatus)
+	if name := c.Form("post_type"); name != "" {
+		var ok bool
+		if postType, ok = postTypeFromName(name); !ok {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+	}
+
+	draft := models.NewDraft(postType, c.User)
+	if !setDraftContent(c, draft) {
+		return
+	}
+
+	if err := draft.Save(c.Conn); err != nil {
+		removeDraftPhotos(c, draft.Photos)
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(201, map[string]interface{}{
+		"message": "Draft saved successfully",
+		"draft":   draft,
+	})
+}
+
+// ListDrafts retrieves the drafts of the user, the most recently updated first
+func ListDrafts(c middleware.Context) {
+	count, offset := c.ListCountParams()
+	drafts := make([]models.Draft, 0, count)
+
+	if err := c.Find("drafts", bson.M{"user_id": c.User.ID}).Sort("-updated").Skip(offset).Limit(count).All(&drafts); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"drafts": drafts,
+		"count":  len(drafts),
+	})
+}
+
+// ShowDraft returns a draft of the user
+func ShowDraft(c middleware.Context, params martini.Params) {
+	draft, ok := findDraft(c, params["id"])
+	if !ok {
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"draft": draft,
+	})
+}
+
+// UpdateDraft updates the content of a draft of the user. Only the given parameters are modified and the
+// uploaded images are added after the ones the draft already had.
+//
+// The following parameters are optional:
+// - post_text, post_url, post_id, album_id, target_user_id, privacy_type, privacy_users: Same as in CreatePost
+// - content_warning, sensitive: Same as in CreatePost
+// - post_picture: New images of the post, only for photo drafts
+// - photo_captions: Captions of the photos in order, only for photo drafts
+// - poll_options, poll_multiple, poll_duration: Same as in CreatePost, only for poll drafts
+// - remove_photos: Indexes of the photos that will be removed from the draft
+func UpdateDraft(c middleware.Context, params martini.Params) {
+	draft, ok := findDraft(c, params["id"])
+	if !ok {
+		return
+	}
+
+	var removed []models.Photo
+	if indexes, ok := c.Request.Form["remove_photos"]; ok {
+		remove := make(map[int]bool)
+		for _, idx := range indexes {
+			i, err := strconv.Atoi(idx)
+			if err != nil || i < 0 || i >= len(draft.Photos) {
+				c.Error(400, CodeInvalidData, MsgInvalidData)
+				return
+			}
+
+			remove[i] = true
+		}
+
+		photos := make([]models.Photo, 0, len(draft.Photos))
+		for i, photo := range draft.Photos {
+			if remove[i] {
+				removed = append(removed, photo)
+			} else {
+				photos = append(photos, photo)
+			}
+		}
+
+		draft.Photos = photos
+	}
+
+	previous := len(draft.Photos)
+	if !setDraftContent(c, draft) {
+		return
+	}
+
+	draft.Updated = float64(time.Now().Unix())
+	if err := draft.Save(c.Conn); err != nil {
+		removeDraftPhotos(c, draft.Photos[previous:])
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	removeDraftPhotos(c, removed)
+
+	c.Success(200, map[string]interface{}{
+		"message": "Draft updated successfully",
+		"draft":   draft,
+	})
+}
+
+// DeleteDraft removes a draft of the user along with its photos
+func DeleteDraft(c middleware.Context, params martini.Params) {
+	draft, ok := findDraft(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if err := jobs.DeleteDraft(c, draft); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"deleted": true,
+		"message": "Draft deleted successfully",
+	})
+}
+
+// PublishDraft creates a post with the content of a draft of the user. The post is validated exactly as
+// it would be in CreatePost and the draft is only removed if the post is created. Any of the parameters
+// accepted by CreatePost can be given to override the content of the draft, except post_type.
+func PublishDraft(c middleware.Context, params martini.Params) {
+	draft, ok := findDraft(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if c.Request.PostForm == nil {
+		c.Request.PostForm = make(url.Values)
+	}
+
+	setParam := func(key string, values ...string) {
+		c.Request.Form[key] = values
+		c.Request.PostForm[key] = values
+	}
+
+	setDefault := func(key string, values ...string) {
+		if _, ok := c.Request.Form[key]; !ok && len(values) > 0 && values[0] != "" {
+			setParam(key, values...)
+		}
+	}
+
+	setParam("post_type", postTypeNames[draft.Type])
+	setDefault("post_text", draft.Text)
+	setDefault("post_url", draft.URL)
+
+	if draft.PostID.Hex() != "" {
+		setDefault("post_id", draft.PostID.Hex())
+	}
+
+	if draft.AlbumID.Hex() != "" {
+		setDefault("album_id", draft.AlbumID.Hex())
+	}
+
+	if draft.TargetID.Hex() != "" {
+		setDefault("target_user_id", draft.TargetID.Hex())
+	}
+
+	if _, ok := c.Request.Form["privacy_type"]; !ok && draft.Privacy.Type != 0 {
+		setParam("privacy_type", strconv.Itoa(int(draft.Privacy.Type)))
+
+		users := make([]string, 0, len(draft.Privacy.Users))
+		for _, u := range draft.Privacy.Users {
+			users = append(users, u.Hex())
+		}
+		setParam("privacy_users", users...)
+	}
+
+	setDefault("content_warning", draft.ContentWarning)
+	if draft.Sensitive {
+		setDefault("sensitive", "true")
+	}
+
+	setDefault("poll_options", draft.PollOptions...)
+	if draft.PollMultiple {
+		setDefault("poll_multiple", "true")
+	}
+
+	if draft.PollDuration > 0 {
+		setDefault("poll_duration", strconv.FormatInt(draft.PollDuration, 10))
+	}
+
+	if len(draft.Photos) > 0 {
+		captions := make([]string, 0, len(draft.Photos))
+		for _, photo := range draft.Photos {
+			captions = append(captions, photo.Caption)
+		}
+		setDefault("photo_captions", captions...)
+	}
+
+	// The photos of the draft now belong to the post, so they are not removed along with the draft
+	if post := createPost(c, draft.Photos); post != nil {
+		c.Query("drafts").RemoveId(draft.ID)
+	}
+}
+
+// findDraft retrieves the draft with the given id if it belongs to the user, reporting the error otherwise
+func findDraft(c middleware.Context, draftID string) (*models.Draft, bool) {
+	var draft models.Draft
+
+	if !bson.IsObjectIdHex(draftID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil, false
+	}
+
+	if err := c.FindId("drafts", bson.ObjectIdHex(draftID)).One(&draft); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil, false
+	}
+
+	if draft.UserID.Hex() != c.User.ID.Hex() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return nil, false
+	}
+
+	// Parse the form so the given parameters can be told apart from the empty ones
+	c.Request.ParseMultipartForm(32 << 20)
+
+	return &draft, true
+}
+
+// setDraftContent sets the given parameters in the draft and stores the uploaded images, reporting the
+// error if any of them is not valid
+func setDraftContent(c middleware.Context, draft *models.Draft) bool {
+	form := c.Request.Form
+
+	if _, ok := form["post_text"]; ok {
+		draft.Text = strings.TrimSpace(c.Form("post_text"))
+		if util.Strlen(draft.Text) > 1500 {
+			c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
+			return false
+		}
+	}
+
+	if _, ok := form["post_url"]; ok {
+		draft.URL = strings.TrimSpace(c.Form("post_url"))
+		if len(draft.URL) > 2048 {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return false
+		}
+	}
+
+	for key, field := range map[string]*bson.ObjectId{
+		"post_id":        &draft.PostID,
+		"album_id":       &draft.AlbumID,
+		"target_user_id": &draft.TargetID,
+	} {
+		if _, ok := form[key]; !ok {
+			continue
+		}
+
+		ID := c.Form(key)
+		if ID != "" && !bson.IsObjectIdHex(ID) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return false
+		}
+
+		*field = ""
+		if ID != "" {
+			*field = bson.ObjectIdHex(ID)
+		}
+	}
+
+	if _, ok := form["privacy_type"]; ok {
+		pType, err := strconv.ParseInt(c.Form("privacy_type"), 10, 8)
+		if err != nil || (pType != 0 && !models.IsValidPrivacyType(models.PrivacyType(pType))) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return false
+		}
+
+		draft.Privacy = models.PrivacySettings{Type: models.PrivacyType(pType)}
+		for _, u := range form["privacy_users"] {
+			if bson.IsObjectIdHex(u) {
+				draft.Privacy.Users = append(draft.Privacy.Users, bson.ObjectIdHex(u))
+			}
+		}
+	}
+
+	if _, ok := form["content_warning"]; ok {
+		draft.ContentWarning = strings.TrimSpace(c.Form("content_warning"))
+		if util.Strlen(draft.ContentWarning) > maxContentWarningLength {
+			c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+			return false
+		}
+	}
+
+	if _, ok := form["sensitive"]; ok {
+		draft.Sensitive = c.GetBoolean("sensitive")
+	}
+
+	if draft.Type == models.PostPoll {
+		return setDraftPoll(c, draft)
+	}
+
+	if draft.Type != models.PostPhoto {
+		return true
+	}
+
+	captions := form["photo_captions"]
+	for _, caption := range captions {
+		if util.Strlen(strings.TrimSpace(caption)) > 255 {
+			c.Error(400, CodeInvalidCaption, MsgInvalidCaption)
+			return false
+		}
+	}
+
+	files, fileErrors, positions, err := upload.RetrieveUploadedImages(c.Request, "post_picture")
+	if err != nil {
+		if code, msg := upload.CodeAndMessageForUploadError(err); code != CodeNoFileUploaded {
+			c.Error(400, code, msg)
+			return false
+		}
+	}
+
+	if len(draft.Photos)+len(files) > models.MaxPostPhotos {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+
+		c.Error(400, CodeTooManyPhotos, MsgTooManyPhotos)
+		return false
+	}
+
+	var (
+		stored   = make([]models.Photo, len(files))
+		failed   []int
+		codes    []int
+		messages []string
+	)
+
+	for i, f := range files {
+		err := fileErrors[i]
+		if err == nil {
+			stored[i].URL, stored[i].Thumbnail, err = upload.StoreImage(f, upload.DefaultUploadOptions(c.Config))
+		}
+
+		if err != nil {
+			code, msg := upload.CodeAndMessageForUploadError(err)
+			failed = append(failed, positions[i])
+			codes = append(codes, code)
+			messages = append(messages, msg)
+		}
+	}
+
+	if len(failed) > 0 {
+		removeDraftPhotos(c, stored)
+		c.FileErrors(400, failed, codes, messages)
+		return false
+	}
+
+	draft.Photos = append(draft.Photos, stored...)
+	for i := range draft.Photos {
+		if i < len(captions) {
+			draft.Photos[i].Caption = strings.TrimSpace(captions[i])
+		}
+	}
+
+	return true
+}
+
+// removeDraftPhotos removes the stored files of the given photos
+func removeDraftPhotos(c middleware.Context, photos []models.Photo) {
+	for _, photo := range photos {
+		if photo.URL != "" {
+			upload.RemoveImage(photo.URL, photo.Thumbnail, c.Config)
+		}
+	}
+}
+
+// setDraftPoll sets the given poll parameters in the draft, reporting the error if any of them is not valid.
+// Empty options are ignored since the draft can still be incomplete.
+func setDraftPoll(c middleware.Context, draft *models.Draft) bool {
+	form := c.Request.Form
+
+	if values, ok := form["poll_options"]; ok {
+		options := make([]string, 0, len(values))
+		for _, option := range values {
+			option = strings.TrimSpace(option)
+			if util.Strlen(option) > models.MaxPollOptionLength {
+				c.Error(400, CodeInvalidPoll, MsgInvalidPoll)
+				return false
+			}
+
+			if option != "" {
+				options = append(options, option)
+			}
+		}
+
+		if len(options) > models.MaxPollOptions {
+			c.Error(400, CodeInvalidPoll, MsgInvalidPoll)
+			return false
+		}
+
+		draft.PollOptions = options
+	}
+
+	if _, ok := form["poll_multiple"]; ok {
+		draft.PollMultiple = c.GetBoolean("poll_multiple")
+	}
+
+	if _, ok := form["poll_duration"]; ok {
+		draft.PollDuration = 0
+		if duration := c.Form("poll_duration"); duration != "" {
+			seconds, err := strconv.ParseInt(duration, 10, 64)
+			if err != nil || seconds < models.MinPollDuration || seconds > models.MaxPollDuration {
+				c.Error(400, CodeInvalidPollDuration, MsgInvalidPollDuration)
+				return false
+			}
+
+			draft.PollDuration = seconds
+		}
+	}
+
+	return true
+}
+
+// postTypeFromName returns the post type with the given post_type name
+func postTypeFromName(name string) (models.ObjectType, bool) {
+	for t, n := range postTypeNames {
+		if n == name {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+	"strconv"
+	"strings"
+)
+
+// GetHashtagPosts retrieves the newest posts with the given hashtag the user can access
+//
+// This handler accepts the following optional parameters:
+// - older_than: Retrieve only posts older than the given timestamp
+// - newer_than: Retrieve only posts newer than the given timestamp
+func GetHashtagPosts(c middleware.Context, params martini.Params) {
+	var (
+		posts = make([]models.Post, 0, 25)
+		ids   = make([]bson.ObjectId, 0, 25)
+		users = make([]bson.ObjectId, 0, 25)
+		p     models.Post
+	)
+
+	tags := models.ParseHashtags("#" + strings.TrimPrefix(params["tag"], "#"))
+	if len(tags) != 1 {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	newerThan, err := strconv.ParseInt(c.Form("newer_than"), 10, 64)
+	if err != nil {
+		newerThan = 0
+	}
+
+	olderThan, err := strconv.ParseInt(c.Form("older_than"), 10, 64)
+	if err != nil {
+		olderThan = 0
+	}
+
+	var timeConstraint bson.M
+	if olderThan > 0 {
+		timeConstraint = bson.M{"$lt": olderThan}
+	} else {
+		timeConstraint = bson.M{"$gt": newerThan}
+	}
+
+	iter := c.Find("posts", bson.M{"hashtags": tags[0], "created": timeConstraint}).Sort("-created").Iter()
+	for len(posts) < 25 && iter.Next(&p) {
+		if (&p).CanBeAccessedBy(c.User, c.Conn) {
+			posts = append(posts, p)
+			ids = append(ids, p.ID)
+			users = append(users, p.UserID)
+		}
+	}
+
+	iter.Close()
+
+	udata := models.GetUsersData(users, c.User, c.Conn)
+	reactions := models.GetReactionsForPosts(ids, c.User.ID, c.Conn)
+
+	result := make([]models.Post, 0, len(posts))
+	for _, v := range posts {
+		// Skip posts whose authors have deleted their account
+		u, ok := udata[v.UserID]
+		if !ok {
+			continue
+		}
+		v.User = u
+
+		v.Reaction = reactions[v.ID]
+
+		result = append(result, v)
+	}
+
+	models.SetPostTargets(result, c.User, c.Conn)
+	models.SetReshareOriginals(result, c.User, c.Conn)
+	models.SetPollVotes(result, c.User, c.Conn)
+	models.SetCollapsed(result, c.User)
+
+	c.Success(200, map[string]interface{}{
+		"hashtag": tags[0],
+		"posts":   result,
+		"count":   len(result),
+	})
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+)
+
+// ShowJob returns the progress of a background job started by the user or its summary if it has finished
+func ShowJob(c middleware.Context, params martini.Params) {
+	var job models.Job
+
+	jobID := params["id"]
+	if !bson.IsObjectIdHex(jobID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("jobs", bson.ObjectIdHex(jobID)).One(&job); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if job.UserID.Hex() != c.User.ID.Hex() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	response := map[string]interface{}{
+		"job": job,
+	}
+
+	if job.Type == models.JobDataExport && job.File != "" && !job.Expired() {
+		response["download_url"] = "/account/export/" + job.ID.Hex()
+	}
+
+	c.Success(200, response)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// PinPost pins a post of the user to the top of the user's profile. Only models.MaxPinnedPosts posts
+// can be pinned at the same time.
+func PinPost(c middleware.Context, params martini.Params) {
+	post, ok := findPinPost(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if post.Pinned == 0 {
+		if !post.CanBePinned(c.Conn) {
+			c.Error(400, CodeCantPinPost, MsgCantPinPost)
+			return
+		}
+
+		pinned, err := c.Count("posts", bson.M{"user_id": c.User.ID, "pinned": bson.M{"$gt": 0}})
+		if err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+
+		if pinned >= models.MaxPinnedPosts {
+			c.Error(400, CodeTooManyPinnedPosts, MsgTooManyPinnedPosts)
+			return
+		}
+
+		post.Pinned = float64(time.Now().Unix())
+		if err := c.Query("posts").UpdateId(post.ID, bson.M{"$set": bson.M{"pinned": post.Pinned}}); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"pinned":  true,
+		"message": "Post pinned successfully",
+	})
+}
+
+// UnpinPost removes a post of the user from the top of the user's profile
+func UnpinPost(c middleware.Context, params martini.Params) {
+	post, ok := findPinPost(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if post.Pinned > 0 {
+		if err := c.Query("posts").UpdateId(post.ID, bson.M{"$unset": bson.M{"pinned": ""}}); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"pinned":  false,
+		"message": "Post unpinned successfully",
+	})
+}
+
+// findPinPost retrieves the post with the given id if it belongs to the user, reporting the error otherwise
+func findPinPost(c middleware.Context, postID string) (*models.Post, bool) {
+	var post models.Post
+
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil, false
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil, false
+	}
+
+	if post.UserID.Hex() != c.User.ID.Hex() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return nil, false
+	}
+
+	return &post, true
+}
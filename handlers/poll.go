@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"strconv"
+	"time"
+)
+
+// VotePoll votes the given options in a poll the user can access. Users can only vote once in each poll
+// and only while the poll is open.
+//
+// The following parameters are required:
+// - options: Indexes of the voted options, only one unless the poll allows multiple choices
+func VotePoll(c middleware.Context, params martini.Params) {
+	post, ok := findAccessiblePost(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if post.Type != models.PostPoll || post.Poll == nil {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if post.Poll.IsClosed() {
+		c.Error(403, CodePollClosed, MsgPollClosed)
+		return
+	}
+
+	c.Request.ParseForm()
+	options := make([]int, 0, len(c.Request.Form["options"]))
+	for _, o := range c.Request.Form["options"] {
+		i, err := strconv.Atoi(o)
+		if err != nil {
+			c.Error(400, CodeInvalidVote, MsgInvalidVote)
+			return
+		}
+
+		options = append(options, i)
+	}
+
+	if !post.Poll.IsValidVote(options) {
+		c.Error(400, CodeInvalidVote, MsgInvalidVote)
+		return
+	}
+
+	if count, err := c.Count("poll_votes", bson.M{"post_id": post.ID, "user_id": c.User.ID}); err != nil || count > 0 {
+		c.Error(403, CodeAlreadyVoted, MsgAlreadyVoted)
+		return
+	}
+
+	vote := models.PollVote{
+		ID:      bson.NewObjectId(),
+		PostID:  post.ID,
+		UserID:  c.User.ID,
+		Options: options,
+		Created: float64(time.Now().Unix()),
+	}
+
+	// Another request of the user may have voted meanwhile
+	if err := c.Query("poll_votes").Insert(vote); mgo.IsDup(err) {
+		c.Error(403, CodeAlreadyVoted, MsgAlreadyVoted)
+		return
+	} else if err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	inc := bson.M{"poll.voters": 1}
+	for _, o := range options {
+		inc["poll.options."+strconv.Itoa(o)+".votes"] = 1
+	}
+
+	if err := c.Query("posts").UpdateId(post.ID, bson.M{"$inc": inc}); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if err := c.FindId("posts", post.ID).One(post); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	post.Poll.Voted = options
+
+	c.Success(200, map[string]interface{}{
+		"message": "Vote registered successfully",
+		"poll":    post.Poll,
+	})
+}
@@ -6,34 +6,65 @@ import (
 	. "github.com/mvader/sunglasses/error"
 	"github.com/mvader/sunglasses/middleware"
 	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/modules/preview"
 	"github.com/mvader/sunglasses/modules/timeline"
 	"github.com/mvader/sunglasses/modules/upload"
-	"github.com/mvader/sunglasses/modules/video"
 	"github.com/mvader/sunglasses/util"
+	"io"
 	"labix.org/v2/mgo/bson"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// CreatePost creates a new post
+// CreatePost creates a new post. If the target_user_id parameter is given the post will be posted
+// in the profile of that user, as long as the user allows it and none of them has blocked the other.
 func CreatePost(c middleware.Context) {
+	createPost(c, nil)
+}
+
+// createPost creates a new post from the request parameters and returns it, or nil if it could not be
+// created. Photo posts use the given already stored photos instead of the uploaded ones if there are any.
+func createPost(c middleware.Context, photos []models.Photo) *models.Post {
+	var target *models.User
+
+	if targetID := c.Form("target_user_id"); targetID != "" && targetID != c.User.ID.Hex() {
+		if !bson.IsObjectIdHex(targetID) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return nil
+		}
+
+		if target = models.UserExists(c.Conn, bson.ObjectIdHex(targetID)); target == nil {
+			c.Error(404, CodeUserDoesNotExist, MsgUserDoesNotExist)
+			return nil
+		}
+
+		if !target.Settings.AllowPostsInMyProfile ||
+			models.UserIsBlocked(target.ID, c.User.ID, c.Conn) ||
+			models.UserIsBlocked(c.User.ID, target.ID, c.Conn) {
+			c.Error(403, CodeCantPostOnProfile, MsgCantPostOnProfile)
+			return nil
+		}
+	}
+
 	postType := c.Form("post_type")
 
 	switch postType {
 	case "photo":
-		postPhoto(c)
-		break
+		return postPhoto(c, target, photos)
 	case "video":
-		postVideo(c)
-		break
+		return postVideo(c, target)
 	case "link":
-		postLink(c)
-		break
-	default:
-		// Default post type is status
-		postStatus(c)
+		return postLink(c, target)
+	case "reshare":
+		return postReshare(c, target)
+	case "poll":
+		return postPoll(c, target)
 	}
+
+	// Default post type is status
+	return postStatus(c, target)
 }
 
 // ShowPost returns all data about a post including comments and likes
@@ -46,14 +77,14 @@ func ShowPost(c middleware.Context, params martini.Params) {
 		return
 	}
 
-	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil {
+	// Scheduled posts are not shown until they are published and expired ones are gone even if they
+	// have not been deleted yet
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil || post.Scheduled > 0 || post.Expired() {
 		c.Error(404, CodeNotFound, MsgNotFound)
 		return
 	}
 
-	var like models.PostLike
-	err := c.Find("likes", bson.M{"post_id": post.ID, "user_id": c.User.ID}).One(&like)
-	post.Liked = err == nil
+	post.Reaction = models.GetReactionsForPosts([]bson.ObjectId{post.ID}, c.User.ID, c.Conn)[post.ID]
 
 	if !post.CanBeAccessedBy(c.User, c.Conn) {
 		c.Error(403, CodeUnauthorized, MsgUnauthorized)
@@ -67,14 +98,27 @@ func ShowPost(c middleware.Context, params martini.Params) {
 
 	udata := models.GetUsersData([]bson.ObjectId{post.UserID}, c.User, c.Conn)
 
-	post.User = udata[post.UserID]
+	// The author of the post has deleted the account
+	user, ok := udata[post.UserID]
+	if !ok {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	post.User = user
+
+	posts := []models.Post{post}
+	models.SetPostTargets(posts, c.User, c.Conn)
+	models.SetReshareOriginals(posts, c.User, c.Conn)
+	models.SetPollVotes(posts, c.User, c.Conn)
+	models.SetCollapsed(posts, c.User)
 
 	c.Success(200, map[string]interface{}{
-		"post": post,
+		"post": posts[0],
 	})
 }
 
-// DeletePost deletes a post owned by the user making the request
+// DeletePost deletes a post owned by the user making the request or posted in their profile
 func DeletePost(c middleware.Context, params martini.Params) {
 	var post models.Post
 
@@ -89,35 +133,34 @@ func DeletePost(c middleware.Context, params martini.Params) {
 		return
 	}
 
-	if c.User.ID.Hex() != post.UserID.Hex() {
+	// Posts can be deleted by their author and by the owner of the profile they were posted in.
+	// Scheduled posts can only be cancelled by their author.
+	if c.User.ID.Hex() != post.UserID.Hex() && (c.User.ID.Hex() != post.TargetID.Hex() || post.Scheduled > 0) {
 		c.Error(403, CodeUnauthorized, MsgUnauthorized)
 		return
 	}
 
-	if err := c.Query("posts").RemoveId(post.ID); err != nil {
+	if err := jobs.DeletePost(c, &post); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
 
-	if post.Type == models.PostPhoto {
-		os.Remove(upload.ToLocalImagePath(post.PhotoURL, c.Config))
-		os.Remove(upload.ToLocalThumbnailPath(post.Thumbnail, c.Config))
-	}
-
-	c.RemoveAll("comments", bson.M{"post_id": post.ID})
-	c.RemoveAll("likes", bson.M{"post_id": post.ID})
-	c.RemoveAll("notifications", bson.M{"post_id": post.ID})
-
-	go timeline.PropagatePostsOnDeletion(c, post.ID)
-
 	c.Success(200, map[string]interface{}{
 		"deleted": true,
 		"message": "Post deleted successfully",
 	})
 }
 
-// LikePost likes a post (or unlikes it if the post has already been liked)
-func LikePost(c middleware.Context, params martini.Params) {
+// EditPost edits the text of a post owned by the user making the request. The captions of the photos
+// can be edited too in photo posts. The previous content of the post is kept as a revision.
+//
+// The following parameters are optional but at least one of them must be given:
+// - post_text: New text of the post
+// - caption: New caption of the photo, only for photo posts with one photo
+// - photo_captions: New captions of the photos in order, only for photo posts
+// - content_warning: New content warning of the post, empty to remove it
+// - sensitive: Whether the media of the post is sensitive
+func EditPost(c middleware.Context, params martini.Params) {
 	var post models.Post
 
 	postID := params["id"]
@@ -131,76 +174,164 @@ func LikePost(c middleware.Context, params martini.Params) {
 		return
 	}
 
-	if !post.CanBeAccessedBy(c.User, c.Conn) {
+	if c.User.ID.Hex() != post.UserID.Hex() {
 		c.Error(403, CodeUnauthorized, MsgUnauthorized)
 		return
 	}
 
-	count, _ := c.Find("likes", bson.M{"post_id": post.ID, "user_id": c.User.ID}).Count()
+	revision := models.NewPostRevision(&post, float64(time.Now().Unix()))
+	modified := false
 
-	// Post was already liked by the user, unlike it
-	if count > 0 {
-		post.Likes--
-		if err := (&post).Save(c.Conn); err != nil {
-			c.Error(500, CodeUnexpected, MsgUnexpected)
+	text := strings.TrimSpace(c.Form("post_text"))
+	if _, ok := c.Request.Form["post_text"]; ok && text != post.Text {
+		if util.Strlen(text) > 1500 || (post.Type == models.PostStatus && util.Strlen(text) < 1) {
+			c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
 			return
 		}
 
-		if post.UserID.Hex() != c.User.ID.Hex() {
-			c.RemoveAll("notifications", bson.M{"post_id": post.ID, "user_id": post.UserID, "user_action_id": c.User.ID})
+		post.Text = text
+		modified = true
+	}
+
+	if _, ok := c.Request.Form["content_warning"]; ok {
+		warning, _, err := getPostContentWarning(c)
+		if err != nil {
+			c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+			return
 		}
 
-		if _, err := c.RemoveAll("likes", bson.M{"post_id": post.ID, "user_id": c.User.ID}); err != nil {
-			post.Likes++
-			(&post).Save(c.Conn)
+		if warning != post.ContentWarning {
+			post.ContentWarning = warning
+			modified = true
+		}
+	}
 
-			c.Error(500, CodeUnexpected, MsgUnexpected)
+	if _, ok := c.Request.Form["sensitive"]; ok && c.GetBoolean("sensitive") != post.Sensitive {
+		post.Sensitive = !post.Sensitive
+		modified = true
+	}
+
+	if post.Type == models.PostPhoto {
+		captions := c.Request.Form["photo_captions"]
+		if _, ok := c.Request.Form["caption"]; ok && len(captions) == 0 {
+			captions = []string{c.Form("caption")}
+		}
+
+		images := post.Images()
+		if len(captions) > len(images) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
 			return
 		}
 
-		go timeline.PropagatePostOnLike(c, post.ID, false)
+		for i, caption := range captions {
+			caption = strings.TrimSpace(caption)
+			if util.Strlen(caption) > 255 {
+				c.Error(400, CodeInvalidCaption, MsgInvalidCaption)
+				return
+			}
+
+			if caption != images[i].Caption {
+				images[i].Caption = caption
+				modified = true
+			}
+		}
+
+		if len(images) > 0 {
+			post.Caption = images[0].Caption
+		}
+	}
 
+	if !modified {
 		c.Success(200, map[string]interface{}{
-			"liked":   false,
-			"message": "Post unliked successfully",
+			"message": "Post was not modified",
+			"post":    post,
 		})
 		return
 	}
 
-	// Like post
-	post.Likes++
+	// Scheduled posts have not been seen by anyone yet, so they don't keep revisions
+	if post.Scheduled == 0 {
+		if err := revision.Save(c.Conn); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return
+		}
+
+		post.Edited = true
+		post.EditedAt = revision.Replaced
+	}
+
+	previousMentions := post.Mentions
+	(&post).SetEntities(c.Conn)
+
 	if err := (&post).Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
 
-	like := models.PostLike{bson.NewObjectId(), c.User.ID, post.ID}
-	if err := c.Query("likes").Insert(like); err != nil {
-		post.Likes--
-		(&post).Save(c.Conn)
+	if post.Scheduled == 0 {
+		jobs.NotifyMentions(c, &post, nil, previousMentions)
+	}
+
+	c.Success(200, map[string]interface{}{
+		"message": "Post edited successfully",
+		"post":    post,
+	})
+}
+
+// GetPostRevisions retrieves the previous revisions of a post, newest first
+func GetPostRevisions(c middleware.Context, params martini.Params) {
+	var post models.Post
+
+	postID := params["id"]
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
 
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if !post.CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	count, offset := c.ListCountParams()
+	revisions := make([]models.PostRevision, 0, count)
+
+	if err := c.Find("post_revisions", bson.M{"post_id": post.ID}).Sort("-replaced").Skip(offset).Limit(count).All(&revisions); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
 
-	if post.UserID.Hex() != c.User.ID.Hex() {
-		var n models.Notification
-		c.Find("notifications", bson.M{"notification_type": models.NotificationPostLiked, "post_id": post.ID, "user_id": post.UserID}).One(&n)
-		if n.ID.Hex() == "" {
-			var user models.User
-			if err := c.FindId("users", post.UserID).One(&user); err != nil {
-				c.Error(500, CodeUnexpected, MsgUnexpected)
-				return
-			}
+	c.Success(200, map[string]interface{}{
+		"revisions": revisions,
+		"count":     len(revisions),
+	})
+}
 
-			models.SendNotification(models.NotificationPostLiked, &user, post.ID, c.User.ID, c.Conn)
-		} else {
-			n.UserActionID = c.User.ID
-			(&n).Save(c.Conn)
-		}
+// LikePost likes a post (or unlikes it if the post has already been liked). If the user had another reaction
+// to the post it is replaced with a like.
+func LikePost(c middleware.Context, params martini.Params) {
+	post, ok := findAccessiblePost(c, params["id"])
+	if !ok {
+		return
 	}
 
-	go timeline.PropagatePostOnLike(c, post.ID, true)
+	reaction, ok := react(c, post, nil, models.ReactionLike)
+	if !ok {
+		return
+	}
+
+	if reaction == "" {
+		c.Success(200, map[string]interface{}{
+			"liked":   false,
+			"message": "Post unliked successfully",
+		})
+		return
+	}
 
 	c.Success(200, map[string]interface{}{
 		"liked":   true,
@@ -208,171 +339,556 @@ func LikePost(c middleware.Context, params martini.Params) {
 	})
 }
 
-func postPhoto(c middleware.Context) {
-	file, err := upload.RetrieveUploadedImage(c.Request, "post_picture")
-	if err != nil {
-		code, msg := upload.CodeAndMessageForUploadError(err)
-		c.Error(400, code, msg)
-		return
+// postPhoto creates a photo post with all the images uploaded at the post_picture key in the order they were
+// sent. The caption of each image is given in the photo_captions parameter, in the same order, or in the
+// caption parameter if there is only one image. If any of the images can't be stored none of them is kept
+// and the errors are reported for each one of the files.
+// If stored photos are given, such as the ones of a draft, they are used instead of the uploaded images
+// and they are never removed, even if the post can't be created.
+func postPhoto(c middleware.Context, target *models.User, stored []models.Photo) *models.Post {
+	var (
+		files      []io.ReadCloser
+		fileErrors []error
+		positions  []int
+		err        error
+	)
+
+	numPhotos := len(stored)
+	if numPhotos == 0 {
+		files, fileErrors, positions, err = upload.RetrieveUploadedImages(c.Request, "post_picture")
+		if err != nil {
+			code, msg := upload.CodeAndMessageForUploadError(err)
+			c.Error(400, code, msg)
+			return nil
+		}
+
+		numPhotos = len(files)
 	}
 
-	imagePath, thumbnailPath, err := upload.StoreImage(file, upload.DefaultUploadOptions(c.Config))
-	if err != nil {
-		code, msg := upload.CodeAndMessageForUploadError(err)
-		c.Error(400, code, msg)
-		return
+	// Close all the files that have not been stored if the post can't be created
+	closeFiles := func() {
+		for _, f := range files {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}
+
+	if numPhotos > models.MaxPostPhotos {
+		closeFiles()
+		c.Error(400, CodeTooManyPhotos, MsgTooManyPhotos)
+		return nil
+	}
+
+	captions := c.Request.Form["photo_captions"]
+	if _, ok := c.Request.Form["caption"]; ok && len(captions) == 0 && numPhotos == 1 {
+		captions = []string{c.Form("caption")}
 	}
 
 	p := models.NewPost(models.PostPhoto, c.User)
-	p.PhotoURL = imagePath
-	p.Thumbnail = thumbnailPath
-	p.Caption = strings.TrimSpace(c.Form("caption"))
+	setPostTarget(p, target)
+	p.Photos = make([]models.Photo, numPhotos)
+	copy(p.Photos, stored)
+	for i := range p.Photos {
+		if i < len(captions) {
+			p.Photos[i].Caption = strings.TrimSpace(captions[i])
+		}
+
+		if util.Strlen(p.Photos[i].Caption) > 255 {
+			closeFiles()
+			c.Error(400, CodeInvalidCaption, MsgInvalidCaption)
+			return nil
+		}
+	}
+
 	p.Text = strings.TrimSpace(c.Form("post_text"))
 	privacy, err := getPostPrivacy(models.PostPhoto, c)
 	if err != nil {
+		closeFiles()
 		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
-		return
+		return nil
 	}
 
 	p.Privacy = privacy
 
+	if p.Scheduled, err = getPostSchedule(c); err != nil {
+		closeFiles()
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
+
+	if p.Expires, p.ExpiresRelative, err = getPostExpiration(c, p.Scheduled); err != nil {
+		closeFiles()
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if p.ContentWarning, p.Sensitive, err = getPostContentWarning(c); err != nil {
+		closeFiles()
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
+
+	// The photo can be posted directly into one of the user's albums
+	if albumID := c.Form("album_id"); albumID != "" {
+		if !bson.IsObjectIdHex(albumID) {
+			closeFiles()
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return nil
+		}
+
+		count, err := c.Count("albums", bson.M{"_id": bson.ObjectIdHex(albumID), "user_id": c.User.ID})
+		if err != nil || count == 0 {
+			closeFiles()
+			c.Error(404, CodeNotFound, MsgNotFound)
+			return nil
+		}
+
+		p.AlbumID = bson.ObjectIdHex(albumID)
+	}
+
 	if util.Strlen(p.Text) > 1500 {
+		closeFiles()
 		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
-		return
+		return nil
 	}
 
-	if util.Strlen(p.Caption) > 255 {
-		c.Error(400, CodeInvalidCaption, MsgInvalidCaption)
-		return
+	var (
+		failed   []int
+		codes    []int
+		messages []string
+	)
+
+	for i, f := range files {
+		err := fileErrors[i]
+		if err == nil {
+			p.Photos[i].URL, p.Photos[i].Thumbnail, err = upload.StoreImage(f, upload.DefaultUploadOptions(c.Config))
+		}
+
+		if err != nil {
+			code, msg := upload.CodeAndMessageForUploadError(err)
+			failed = append(failed, positions[i])
+			codes = append(codes, code)
+			messages = append(messages, msg)
+		}
 	}
 
+	// Remove all the stored images if the post can't be created
+	removeImages := func() {
+		if len(stored) > 0 {
+			return
+		}
+
+		for _, photo := range p.Photos {
+			if photo.URL != "" {
+				upload.RemoveImage(photo.URL, photo.Thumbnail, c.Config)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		removeImages()
+		c.FileErrors(400, failed, codes, messages)
+		return nil
+	}
+
+	p.PhotoURL = p.Photos[0].URL
+	p.Thumbnail = p.Photos[0].Thumbnail
+	p.Caption = p.Photos[0].Caption
+
+	p.SetEntities(c.Conn)
+
 	if err := p.Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
-
-		os.Remove(p.PhotoURL)
-		os.Remove(p.Thumbnail)
-		return
+		removeImages()
+		return nil
 	}
 
-	go timeline.PropagatePostOnCreation(c, p)
+	// Scheduled posts are published by the post scheduler once they are due
+	if p.Scheduled == 0 {
+		jobs.PublishPost(c, p, target)
+	}
 
 	c.Success(201, map[string]interface{}{
 		"message": "Photo posted successfully",
 		"post":    *p,
 	})
+
+	return p
 }
 
-func postVideo(c middleware.Context) {
+func postVideo(c middleware.Context, target *models.User) *models.Post {
 	statusText := strings.TrimSpace(c.Form("post_text"))
 
 	if util.Strlen(statusText) > 1500 {
 		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
-		return
+		return nil
 	}
 
 	post := models.NewPost(models.PostVideo, c.User)
+	setPostTarget(post, target)
 	post.Text = statusText
 	privacy, err := getPostPrivacy(models.PostVideo, c)
 	if err != nil {
 		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
-		return
+		return nil
 	}
 
 	post.Privacy = privacy
 
-	valid, videoID, service, title := video.IsValidVideo(strings.TrimSpace(c.Form("post_url")))
+	if post.Scheduled, err = getPostSchedule(c); err != nil {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
 
-	if !valid {
+	if post.Expires, post.ExpiresRelative, err = getPostExpiration(c, post.Scheduled); err != nil {
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if post.ContentWarning, post.Sensitive, err = getPostContentWarning(c); err != nil {
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
+
+	v, err := c.Videos.Video(c.Fetcher, strings.TrimSpace(c.Form("post_url")))
+	if err != nil {
 		c.Error(400, CodeInvalidVideoURL, MsgInvalidVideoURL)
-		return
+		return nil
 	}
 
-	post.VideoID = videoID
-	post.Service = service
-	post.Title = title
+	post.VideoID = v.ID
+	post.Service = v.Service
+	post.VideoProvider = v.Provider
+	post.EmbedURL = v.EmbedURL
+	post.VideoThumbnail = v.ThumbnailURL
+	post.VideoDuration = v.Duration
+	post.Title = v.Title
+
+	post.SetEntities(c.Conn)
 
 	if err := post.Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
-		return
+		return nil
 	}
 
-	go timeline.PropagatePostOnCreation(c, post)
+	// Scheduled posts are published by the post scheduler once they are due
+	if post.Scheduled == 0 {
+		jobs.PublishPost(c, post, target)
+	}
 
 	c.Success(201, map[string]interface{}{
 		"message": "Video posted successfully",
 		"post":    *post,
 	})
+
+	return post
 }
 
-func postLink(c middleware.Context) {
+func postLink(c middleware.Context, target *models.User) *models.Post {
 	statusText := strings.TrimSpace(c.Form("post_text"))
 
 	if util.Strlen(statusText) > 1500 {
 		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
-		return
+		return nil
 	}
 
 	post := models.NewPost(models.PostVideo, c.User)
+	setPostTarget(post, target)
 	post.Text = statusText
 	privacy, err := getPostPrivacy(models.PostLink, c)
 	if err != nil {
 		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
-		return
+		return nil
 	}
 
 	post.Privacy = privacy
 
-	valid, link, title := util.IsValidLink(strings.TrimSpace(c.Form("post_url")))
+	if post.Scheduled, err = getPostSchedule(c); err != nil {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
+
+	if post.Expires, post.ExpiresRelative, err = getPostExpiration(c, post.Scheduled); err != nil {
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if post.ContentWarning, post.Sensitive, err = getPostContentWarning(c); err != nil {
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
 
-	if !valid {
+	link := strings.TrimSpace(c.Form("post_url"))
+	if !util.IsValidURL(link) {
 		c.Error(400, CodeInvalidLinkURL, MsgInvalidLinkURL)
-		return
+		return nil
+	}
+
+	linkPreview, err := preview.Get(c.Conn, c.Config, c.Fetcher, link)
+	if err != nil {
+		c.Error(400, CodeInvalidLinkURL, MsgInvalidLinkURL)
+		return nil
 	}
 
 	post.URL = link
-	post.Title = title
+	post.Title = linkPreview.Title
+	post.Preview = linkPreview
+
+	post.SetEntities(c.Conn)
 
 	if err := post.Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
-		return
+		return nil
 	}
 
-	go timeline.PropagatePostOnCreation(c, post)
+	// Scheduled posts are published by the post scheduler once they are due
+	if post.Scheduled == 0 {
+		jobs.PublishPost(c, post, target)
+	}
 
 	c.Success(201, map[string]interface{}{
 		"message": "Link posted successfully",
 		"post":    *post,
 	})
+
+	return post
+}
+
+// postReshare reshares the post with the given post_id. A post can only be reshared with an audience
+// that can access the original post and reshares can't be posted in the profile of other users.
+func postReshare(c middleware.Context, target *models.User) *models.Post {
+	var original models.Post
+
+	if target != nil {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil
+	}
+
+	postID := c.Form("post_id")
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&original); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil
+	}
+
+	if !(&original).CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return nil
+	}
+
+	// Reshares of reshares reshare the original post
+	if original.ReshareOf.Hex() != "" {
+		if err := c.FindId("posts", original.ReshareOf).One(&original); err != nil {
+			c.Error(404, CodeNotFound, MsgNotFound)
+			return nil
+		}
+	}
+
+	statusText := strings.TrimSpace(c.Form("post_text"))
+	if util.Strlen(statusText) > 1500 {
+		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
+		return nil
+	}
+
+	post := models.NewReshare(&original, c.User)
+	post.Text = statusText
+	privacy, err := getPostPrivacy(models.PostReshare, c)
+	if err != nil {
+		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
+		return nil
+	}
+
+	post.Privacy = privacy
+
+	if post.Scheduled, err = getPostSchedule(c); err != nil {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
+
+	if post.Expires, post.ExpiresRelative, err = getPostExpiration(c, post.Scheduled); err != nil {
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if post.ContentWarning, post.Sensitive, err = getPostContentWarning(c); err != nil {
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
+
+	if !(&original).CanBeResharedWith(post.Privacy, c.User, c.Conn) {
+		c.Error(403, CodeCantReshare, MsgCantReshare)
+		return nil
+	}
+
+	post.SetEntities(c.Conn)
+
+	if err := post.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return nil
+	}
+
+	if post.Scheduled == 0 {
+		jobs.PublishPost(c, post, nil)
+	}
+
+	posts := []models.Post{*post}
+	models.SetReshareOriginals(posts, c.User, c.Conn)
+
+	c.Success(201, map[string]interface{}{
+		"message": "Post reshared successfully",
+		"post":    posts[0],
+	})
+
+	return post
 }
 
-func postStatus(c middleware.Context) {
+func postStatus(c middleware.Context, target *models.User) *models.Post {
 	statusText := strings.TrimSpace(c.Form("post_text"))
 
 	if util.Strlen(statusText) < 1 || util.Strlen(statusText) > 1500 {
 		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
-		return
+		return nil
 	}
 
 	post := models.NewPost(models.PostStatus, c.User)
+	setPostTarget(post, target)
 	post.Text = statusText
 	privacy, err := getPostPrivacy(models.PostStatus, c)
 	if err != nil {
 		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
-		return
+		return nil
 	}
 
 	post.Privacy = privacy
+
+	if post.Scheduled, err = getPostSchedule(c); err != nil {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
+
+	if post.Expires, post.ExpiresRelative, err = getPostExpiration(c, post.Scheduled); err != nil {
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if post.ContentWarning, post.Sensitive, err = getPostContentWarning(c); err != nil {
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
+
+	post.SetEntities(c.Conn)
+
 	if err := post.Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
-		return
+		return nil
 	}
 
-	go timeline.PropagatePostOnCreation(c, post)
+	// Scheduled posts are published by the post scheduler once they are due
+	if post.Scheduled == 0 {
+		jobs.PublishPost(c, post, target)
+	}
 
 	c.Success(201, map[string]interface{}{
 		"message": "Status posted successfully",
 		"post":    *post,
 	})
+
+	return post
+}
+
+// postPoll creates a poll whose question is the text of the post. The options of the poll are given in order
+// in the poll_options parameter.
+//
+// The following parameters are optional:
+// - poll_multiple: Whether users can vote more than one option, false by default
+// - poll_duration: Number of seconds the poll is open since it is published, one day by default
+func postPoll(c middleware.Context, target *models.User) *models.Post {
+	question := strings.TrimSpace(c.Form("post_text"))
+	if util.Strlen(question) < 1 || util.Strlen(question) > 1500 {
+		c.Error(400, CodeInvalidStatusText, MsgInvalidStatusText)
+		return nil
+	}
+
+	options := make([]string, 0, len(c.Request.Form["poll_options"]))
+	for _, option := range c.Request.Form["poll_options"] {
+		option = strings.TrimSpace(option)
+		if util.Strlen(option) < 1 || util.Strlen(option) > models.MaxPollOptionLength {
+			c.Error(400, CodeInvalidPoll, MsgInvalidPoll)
+			return nil
+		}
+
+		options = append(options, option)
+	}
+
+	if len(options) < models.MinPollOptions || len(options) > models.MaxPollOptions {
+		c.Error(400, CodeInvalidPoll, MsgInvalidPoll)
+		return nil
+	}
+
+	post := models.NewPost(models.PostPoll, c.User)
+	setPostTarget(post, target)
+	post.Text = question
+	privacy, err := getPostPrivacy(models.PostPoll, c)
+	if err != nil {
+		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
+		return nil
+	}
+
+	post.Privacy = privacy
+
+	if post.Scheduled, err = getPostSchedule(c); err != nil {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return nil
+	}
+
+	if post.Expires, post.ExpiresRelative, err = getPostExpiration(c, post.Scheduled); err != nil {
+		c.Error(400, CodeInvalidExpiration, MsgInvalidExpiration)
+		return nil
+	}
+
+	if post.ContentWarning, post.Sensitive, err = getPostContentWarning(c); err != nil {
+		c.Error(400, CodeInvalidContentWarning, MsgInvalidContentWarning)
+		return nil
+	}
+
+	closes, err := getPollClosing(c, post.Scheduled)
+	if err != nil {
+		c.Error(400, CodeInvalidPollDuration, MsgInvalidPollDuration)
+		return nil
+	}
+
+	post.Poll = models.NewPoll(options, c.GetBoolean("poll_multiple"), closes)
+
+	post.SetEntities(c.Conn)
+
+	if err := post.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return nil
+	}
+
+	// Scheduled posts are published by the post scheduler once they are due
+	if post.Scheduled == 0 {
+		jobs.PublishPost(c, post, target)
+	}
+
+	c.Success(201, map[string]interface{}{
+		"message": "Poll posted successfully",
+		"post":    *post,
+	})
+
+	return post
+}
+
+// setPostTarget sets the user whose profile the post is posted in, if any
+func setPostTarget(post *models.Post, target *models.User) {
+	if target != nil {
+		post.TargetID = target.ID
+	}
 }
 
 func getPostPrivacy(postType models.ObjectType, c middleware.Context) (models.PrivacySettings, error) {
@@ -423,6 +939,101 @@ func getPostPrivacy(postType models.ObjectType, c middleware.Context) (models.Pr
 	return p, nil
 }
 
+// maxPostSchedule is the maximum number of seconds a post can be scheduled in advance
+const maxPostSchedule = 365 * 24 * 3600
+
+// getPostSchedule returns the time at which the post being created will be published, given as a timestamp
+// in the publish_at parameter. A publish time of 0 means the post will be published right away.
+func getPostSchedule(c middleware.Context) (float64, error) {
+	publishAt := c.Form("publish_at")
+	if publishAt == "" {
+		return 0, nil
+	}
+
+	now := time.Now().Unix()
+	t, err := strconv.ParseInt(publishAt, 10, 64)
+	if err != nil || (t != 0 && (t <= now || t > now+maxPostSchedule)) {
+		return 0, errors.New("invalid publish time provided")
+	}
+
+	return float64(t), nil
+}
+
+// getPostExpiration returns the time at which the post being created will expire. It can be given as a
+// number of seconds (expires_in) or as a timestamp (expires_at). If none of them is given the default
+// expiration of the user will be used. An expiration of 0 means the post will not expire.
+// Expirations given in seconds are counted from the publish time for scheduled posts, in which case it
+// also returns true so the expiration can be moved if the post is rescheduled.
+func getPostExpiration(c middleware.Context, scheduled float64) (float64, bool, error) {
+	start := time.Now().Unix()
+	if scheduled > 0 {
+		start = int64(scheduled)
+	}
+
+	if expiresIn := c.Form("expires_in"); expiresIn != "" {
+		seconds, err := strconv.ParseInt(expiresIn, 10, 64)
+		if err != nil || seconds < 0 {
+			return 0, false, errors.New("invalid expiration provided")
+		}
+
+		if seconds == 0 {
+			return 0, false, nil
+		}
+
+		return float64(start + seconds), scheduled > 0, nil
+	}
+
+	if expiresAt := c.Form("expires_at"); expiresAt != "" {
+		t, err := strconv.ParseInt(expiresAt, 10, 64)
+		if err != nil || (t != 0 && t <= start) {
+			return 0, false, errors.New("invalid expiration provided")
+		}
+
+		return float64(t), false, nil
+	}
+
+	if c.User.Settings.DefaultPostExpiration > 0 {
+		return float64(start + c.User.Settings.DefaultPostExpiration), scheduled > 0, nil
+	}
+
+	return 0, false, nil
+}
+
+// maxContentWarningLength is the maximum number of characters of the content warning of a post
+const maxContentWarningLength = 100
+
+// getPostContentWarning returns the content warning of the post being created or edited, given in the
+// content_warning parameter, and whether its media is sensitive, given in the sensitive parameter
+func getPostContentWarning(c middleware.Context) (string, bool, error) {
+	warning := strings.TrimSpace(c.Form("content_warning"))
+	if util.Strlen(warning) > maxContentWarningLength {
+		return "", false, errors.New("invalid content warning provided")
+	}
+
+	return warning, c.GetBoolean("sensitive"), nil
+}
+
+// getPollClosing returns the time at which the poll being created closes, given as a number of seconds
+// since the poll is published in the poll_duration parameter
+func getPollClosing(c middleware.Context, scheduled float64) (float64, error) {
+	start := time.Now().Unix()
+	if scheduled > 0 {
+		start = int64(scheduled)
+	}
+
+	duration := c.Form("poll_duration")
+	if duration == "" {
+		return float64(start + models.DefaultPollDuration), nil
+	}
+
+	seconds, err := strconv.ParseInt(duration, 10, 64)
+	if err != nil || seconds < models.MinPollDuration || seconds > models.MaxPollDuration {
+		return 0, errors.New("invalid poll duration provided")
+	}
+
+	return float64(start + seconds), nil
+}
+
 // ChangePostPrivacy changes a post privacy settings
 func ChangePostPrivacy(c middleware.Context, params martini.Params) {
 	var post models.Post
@@ -449,15 +1060,113 @@ func ChangePostPrivacy(c middleware.Context, params martini.Params) {
 		return
 	}
 
+	if post.ReshareOf.Hex() != "" {
+		var original models.Post
+		if err := c.FindId("posts", post.ReshareOf).One(&original); err != nil || !(&original).CanBeResharedWith(privacy, c.User, c.Conn) {
+			c.Error(403, CodeCantReshare, MsgCantReshare)
+			return
+		}
+	}
+
 	post.Privacy = privacy
 	if err := (&post).Save(c.Conn); err != nil {
 		c.Error(500, CodeUnexpected, MsgUnexpected)
 		return
 	}
 
-	go timeline.PropagatePostOnPrivacyChange(c, &post)
+	jobs.RemoveInvalidReshares(c, post.ID)
+	jobs.UnpinHiddenPosts(c, post.ID)
+
+	if post.Scheduled == 0 {
+		go timeline.PropagatePostOnPrivacyChange(c, &post)
+	}
 
 	c.Success(200, map[string]interface{}{
 		"message": "Post privacy updated successfully",
 	})
 }
+
+// ChangePostsPrivacy changes the privacy settings of all the user posts matching the given filters. Since
+// it may take a while the posts are updated in a background job whose progress can be checked.
+//
+// The following filters are optional:
+// - filter_post_type: Only change the posts of the given type (status, photo, video or link)
+// - filter_privacy_type: Only change the posts with the given privacy type
+// - created_after and created_before: Only change the posts created in the given date range
+func ChangePostsPrivacy(c middleware.Context) {
+	query := bson.M{"user_id": c.User.ID}
+
+	pType, err := strconv.ParseInt(c.Form("privacy_type"), 10, 8)
+	if err != nil || !models.IsValidPrivacyType(models.PrivacyType(pType)) {
+		c.Error(400, CodeInvalidPrivacySettings, MsgInvalidPrivacySettings)
+		return
+	}
+
+	if postType := c.Form("filter_post_type"); postType != "" {
+		switch postType {
+		case "status":
+			query["post_type"] = models.PostStatus
+			break
+		case "photo":
+			query["post_type"] = models.PostPhoto
+			break
+		case "video":
+			query["post_type"] = models.PostVideo
+			break
+		case "link":
+			query["post_type"] = models.PostLink
+			break
+		default:
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+	}
+
+	if privacyType := c.Form("filter_privacy_type"); privacyType != "" {
+		t, err := strconv.ParseInt(privacyType, 10, 8)
+		if err != nil || !models.IsValidPrivacyType(models.PrivacyType(t)) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+
+		query["privacy.privacy_type"] = t
+	}
+
+	created := bson.M{}
+	for param, operator := range map[string]string{"created_after": "$gt", "created_before": "$lt"} {
+		if v := c.Form(param); v != "" {
+			t, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.Error(400, CodeInvalidData, MsgInvalidData)
+				return
+			}
+
+			created[operator] = t
+		}
+	}
+
+	if len(created) > 0 {
+		query["created"] = created
+	}
+
+	privacy, err := getPostPrivacy(models.PostStatus, c)
+	if err != nil {
+		c.Error(400, CodeInvalidUserList, MsgInvalidUserList)
+		return
+	}
+
+	job := models.NewJob(models.JobPostsPrivacyChange, c.User.ID)
+	if err := job.Save(c.Conn); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	// The job is modified while it runs, so the response has a copy of it
+	started := *job
+	go jobs.ChangePostsPrivacy(c, job, query, privacy)
+
+	c.Success(202, map[string]interface{}{
+		"message": "Posts privacy is being updated",
+		"job":     started,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"labix.org/v2/mgo/bson"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // ShowUserProfile retrieves a list of the first 25 posts and the data of the requested user
@@ -15,7 +16,7 @@ func ShowUserProfile(c middleware.Context, params martini.Params) {
 	var u models.User
 
 	user := params["username"]
-	if err := c.Find("users", bson.M{"username_lower": strings.ToLower(user)}).One(&u); err != nil {
+	if err := c.Find("users", bson.M{"username_lower": strings.ToLower(user), "active": true}).One(&u); err != nil {
 		c.Error(404, CodeNotFound, MsgNotFound)
 		return
 	}
@@ -37,7 +38,7 @@ func ShowUserProfile(c middleware.Context, params martini.Params) {
 		timeConstraint = bson.M{"$gt": newerThan}
 	}
 
-	users := models.GetUsersData([]bson.ObjectId{u.ID}, c.User, c.Conn)
+	users := models.GetUsersProfileData([]bson.ObjectId{u.ID}, c.User, c.Conn)
 	if len(users) != 1 {
 		c.Error(404, CodeNotFound, MsgNotFound)
 		return
@@ -58,14 +59,27 @@ func ShowUserProfile(c middleware.Context, params martini.Params) {
 		users[u.ID]["following"] = following
 	}
 
-	numPosts, err := c.Count("posts", bson.M{"user_id": u.ID})
+	// Scheduled and expired posts are not counted, nor hidden posts unless the user is their author
+	postsQuery := bson.M{
+		"user_id":   u.ID,
+		"scheduled": bson.M{"$exists": false},
+		"$or": []bson.M{
+			bson.M{"expires": bson.M{"$exists": false}},
+			bson.M{"expires": bson.M{"$gt": float64(time.Now().Unix())}},
+		},
+	}
+	if u.ID.Hex() != c.User.ID.Hex() {
+		postsQuery["hidden"] = bson.M{"$exists": false}
+	}
+
+	numPosts, err := c.Count("posts", postsQuery)
 	if err != nil {
 		numPosts = 0
 	}
 
 	users[u.ID]["num_posts"] = numPosts
 
-	posts := getPostsFromUser(c, u.ID, timeConstraint)
+	posts := getPostsFromUser(c, u.ID, timeConstraint, olderThan == 0 && newerThan == 0)
 	c.Success(200, map[string]interface{}{
 		"user":        users[u.ID],
 		"posts":       posts,
@@ -98,7 +112,7 @@ func GetUserPosts(c middleware.Context) {
 		timeConstraint = bson.M{"$gt": newerThan}
 	}
 
-	posts := getPostsFromUser(c, bson.ObjectIdHex(userID), timeConstraint)
+	posts := getPostsFromUser(c, bson.ObjectIdHex(userID), timeConstraint, olderThan == 0 && newerThan == 0)
 	if posts == nil {
 		c.Error(404, CodeNotFound, MsgNotFound)
 		return
@@ -111,45 +125,84 @@ func GetUserPosts(c middleware.Context) {
 }
 
 // The main reason to not retrieve the posts from the user's generated timeline is that
-// the timeline for the user may have not been processed yet when the user browses the profile
-func getPostsFromUser(c middleware.Context, user bson.ObjectId, constraint bson.M) []models.Post {
+// the timeline for the user may have not been processed yet when the user browses the profile.
+// Pinned posts are not returned in their place but before the rest of posts if withPinned is true.
+func getPostsFromUser(c middleware.Context, user bson.ObjectId, constraint bson.M, withPinned bool) []models.Post {
 	var (
 		posts = make([]models.Post, 0, 25)
 		ids   = make([]bson.ObjectId, 0, 25)
 		p     models.Post
 	)
 
-	iter := c.Find("posts", bson.M{"user_id": user, "created": constraint}).Sort("-created").Iter()
-	for len(posts) < 25 && iter.Next(&p) {
-		if (&p).CanBeAccessedBy(c.User, c.Conn) {
-			comments := models.GetCommentsForPost(p.ID, c.User, 5, c.Conn)
-			if comments != nil {
-				p.Comments = comments
+	addPost := func(p models.Post) {
+		comments := models.GetCommentsForPost(p.ID, c.User, 5, c.Conn)
+		if comments != nil {
+			p.Comments = comments
+		}
+
+		posts = append(posts, p)
+		ids = append(ids, p.ID)
+	}
+
+	if withPinned {
+		iter := c.Find("posts", bson.M{"user_id": user, "pinned": bson.M{"$gt": 0}}).Sort("-pinned").Iter()
+		for iter.Next(&p) {
+			if (&p).CanBeAccessedBy(c.User, c.Conn) {
+				addPost(p)
 			}
+		}
 
-			posts = append(posts, p)
-			ids = append(ids, p.ID)
+		iter.Close()
+	}
+
+	// Posts of the user and posts of other users in the user's profile
+	limit := len(posts) + 25
+	iter := c.Find("posts", bson.M{
+		"$or":     []bson.M{bson.M{"user_id": user}, bson.M{"target_id": user}},
+		"created": constraint,
+		"pinned":  bson.M{"$exists": false},
+	}).Sort("-created").Iter()
+	for len(posts) < limit && iter.Next(&p) {
+		if (&p).CanBeAccessedBy(c.User, c.Conn) {
+			addPost(p)
 		}
 	}
 
 	iter.Close()
 
-	udata := models.GetUsersData([]bson.ObjectId{user}, c.User, c.Conn)
+	users := []bson.ObjectId{user}
+	for _, p := range posts {
+		if p.UserID != user {
+			users = append(users, p.UserID)
+		}
+	}
+
+	udata := models.GetUsersData(users, c.User, c.Conn)
 
-	if len(udata) == 0 {
+	if _, ok := udata[user]; !ok {
 		return nil
 	}
 
-	likes := models.GetLikesForPosts(ids, c.User.ID, c.Conn)
+	reactions := models.GetReactionsForPosts(ids, c.User.ID, c.Conn)
 
-	for i, v := range posts {
-		posts[i].User = udata[v.UserID]
-		if likes != nil {
-			if l, ok := likes[v.ID]; ok {
-				posts[i].Liked = l
-			}
+	result := make([]models.Post, 0, len(posts))
+	for _, v := range posts {
+		// Skip posts in the user's profile whose authors have deleted their account
+		u, ok := udata[v.UserID]
+		if !ok {
+			continue
 		}
+		v.User = u
+
+		v.Reaction = reactions[v.ID]
+
+		result = append(result, v)
 	}
 
-	return posts
+	models.SetPostTargets(result, c.User, c.Conn)
+	models.SetReshareOriginals(result, c.User, c.Conn)
+	models.SetPollVotes(result, c.User, c.Conn)
+	models.SetCollapsed(result, c.User)
+
+	return result
 }
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// ReactToPost sets the reaction of the user to a post. If the user already had the same reaction to the
+// post it is removed and if the user had a different one it is replaced.
+//
+// The following parameters are required:
+// - reaction: Type of the reaction
+func ReactToPost(c middleware.Context, params martini.Params) {
+	reaction := models.ReactionType(c.Form("reaction"))
+	if !models.IsValidReaction(reaction) {
+		c.Error(400, CodeInvalidReaction, MsgInvalidReaction)
+		return
+	}
+
+	post, ok := findAccessiblePost(c, params["id"])
+	if !ok {
+		return
+	}
+
+	if reaction, ok = react(c, post, nil, reaction); !ok {
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"reaction": reaction,
+		"message":  "Reaction updated successfully",
+	})
+}
+
+// ReactToComment sets the reaction of the user to a comment. If the user already had the same reaction to
+// the comment it is removed and if the user had a different one it is replaced.
+//
+// The following parameters are required:
+// - reaction: Type of the reaction
+func ReactToComment(c middleware.Context, params martini.Params) {
+	var comment models.Comment
+
+	reaction := models.ReactionType(c.Form("reaction"))
+	if !models.IsValidReaction(reaction) {
+		c.Error(400, CodeInvalidReaction, MsgInvalidReaction)
+		return
+	}
+
+	commentID := params["comment_id"]
+	if !bson.IsObjectIdHex(commentID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	// Deleted comments are only kept as placeholders for their replies
+	if err := c.FindId("comments", bson.ObjectIdHex(commentID)).One(&comment); err != nil || comment.Deleted ||
+		!comment.VisibleTo(c.User) {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	post, ok := findAccessiblePost(c, comment.PostID.Hex())
+	if !ok {
+		return
+	}
+
+	if reaction, ok = react(c, post, &comment, reaction); !ok {
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"reaction": reaction,
+		"message":  "Reaction updated successfully",
+	})
+}
+
+// ListPostLikes retrieves a list with the users who have reacted to a post, the most recent first. Users
+// blocked by the user or who have blocked the user are not listed.
+//
+// The following parameters are optional:
+// - reaction: Only list the users with the given reaction
+func ListPostLikes(c middleware.Context, params martini.Params) {
+	var result models.Reaction
+
+	count, offset := c.ListCountParams()
+	post, ok := findAccessiblePost(c, params["id"])
+	if !ok {
+		return
+	}
+
+	blocked, err := models.BlockedUsers(c.User.ID, c.Conn)
+	if err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	query := models.ReactionQuery(post.ID, "")
+	query["user_id"] = bson.M{"$nin": blocked}
+
+	if r := models.ReactionType(c.Form("reaction")); r != "" {
+		if !models.IsValidReaction(r) {
+			c.Error(400, CodeInvalidReaction, MsgInvalidReaction)
+			return
+		}
+
+		// Likes stored before there were more reactions don't have a reaction
+		query["reaction"] = r
+		if r == models.ReactionLike {
+			query["reaction"] = bson.M{"$in": []interface{}{r, nil}}
+		}
+	}
+
+	reactions := make([]models.Reaction, 0, count)
+	cursor := c.Find("likes", query).Sort("-_id").Limit(count).Skip(offset).Iter()
+	for cursor.Next(&result) {
+		reactions = append(reactions, result)
+	}
+
+	if err := cursor.Close(); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	users := make([]bson.ObjectId, 0, len(reactions))
+	for _, r := range reactions {
+		users = append(users, r.UserID)
+	}
+
+	usersData := models.GetUsersData(users, c.User, c.Conn)
+	if usersData == nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	likesResponse := make([]map[string]interface{}, 0, len(reactions))
+	for _, r := range reactions {
+		if v, ok := usersData[r.UserID]; ok {
+			likesResponse = append(likesResponse, map[string]interface{}{
+				"reaction": r.Kind(),
+				"user":     v,
+			})
+		}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"likes": likesResponse,
+		"count": len(likesResponse),
+	})
+}
+
+// findAccessiblePost retrieves the post with the given id if the user can access it, reporting the error otherwise
+func findAccessiblePost(c middleware.Context, postID string) (*models.Post, bool) {
+	var post models.Post
+
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return nil, false
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return nil, false
+	}
+
+	if !post.CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return nil, false
+	}
+
+	return &post, true
+}
+
+// react toggles the reaction of the user to the post or, if it's given, to the comment and returns the
+// reaction the user has after the change, which is empty if the reaction was removed
+func react(c middleware.Context, post *models.Post, comment *models.Comment, reaction models.ReactionType) (models.ReactionType, bool) {
+	var (
+		previous  models.Reaction
+		commentID bson.ObjectId
+	)
+
+	col, ID, owner := "posts", post.ID, post.UserID
+	likes, reactions := post.Likes, post.Reactions
+	if comment != nil {
+		col, ID, owner = "comments", comment.ID, comment.UserID
+		likes, reactions = comment.Likes, comment.Reactions
+		commentID = comment.ID
+	}
+
+	// Posts liked before there were more reactions only have the total number of likes
+	if len(reactions) == 0 && likes > 0 {
+		c.Query(col).UpdateId(ID, bson.M{"$set": bson.M{"reactions." + string(models.ReactionLike): likes}})
+	}
+
+	query := models.ReactionQuery(post.ID, commentID)
+	query["user_id"] = c.User.ID
+
+	if err := c.Find("likes", query).One(&previous); err == nil {
+		if err := c.Query("likes").RemoveId(previous.ID); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return "", false
+		}
+
+		c.Query(col).UpdateId(ID, bson.M{"$inc": bson.M{"likes": -1, "reactions." + string(previous.Kind()): -1}})
+		removeReactionNotification(c, owner, post.ID, commentID, previous.Kind())
+
+		// Reacting again with the same reaction removes it
+		if previous.Kind() == reaction {
+			if comment == nil {
+				go timeline.PropagatePostOnReaction(c, post.ID, "")
+			}
+
+			return "", true
+		}
+	}
+
+	r := models.Reaction{
+		ID:        bson.NewObjectId(),
+		UserID:    c.User.ID,
+		PostID:    post.ID,
+		CommentID: commentID,
+		Type:      reaction,
+	}
+
+	// Another request of the user may have reacted meanwhile, its reaction is kept
+	if err := c.Query("likes").Insert(r); mgo.IsDup(err) {
+		if err := c.Find("likes", query).One(&previous); err != nil {
+			c.Error(500, CodeUnexpected, MsgUnexpected)
+			return "", false
+		}
+
+		return previous.Kind(), true
+	} else if err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return "", false
+	}
+
+	c.Query(col).UpdateId(ID, bson.M{"$inc": bson.M{"likes": 1, "reactions." + string(reaction): 1}})
+	notifyReaction(c, owner, post.ID, commentID, reaction)
+
+	if comment == nil {
+		go timeline.PropagatePostOnReaction(c, post.ID, reaction)
+	}
+
+	return reaction, true
+}
+
+// notifyReaction notifies the author of the post or the comment about the new reaction. There is only one
+// notification for each reaction type, which shows the last user who reacted.
+func notifyReaction(c middleware.Context, owner, postID, commentID bson.ObjectId, reaction models.ReactionType) {
+	var (
+		n    models.Notification
+		user models.User
+	)
+
+	if owner.Hex() == c.User.ID.Hex() {
+		return
+	}
+
+	query := reactionNotificationQuery(owner, postID, commentID, reaction)
+	if err := c.Find("notifications", query).One(&n); err == nil {
+		n.UserActionID = c.User.ID
+		n.Time = float64(time.Now().Unix())
+		n.Read = false
+		(&n).Save(c.Conn)
+		return
+	}
+
+	if err := c.FindId("users", owner).One(&user); err != nil || !user.Settings.NotifyLikes {
+		return
+	}
+
+	n.Type = models.NotificationPostLiked
+	if commentID.Hex() != "" {
+		if user.Settings.MuteCommentLikes {
+			return
+		}
+
+		n.Type = models.NotificationCommentReacted
+	}
+
+	n.User = owner
+	n.PostID = postID
+	n.CommentID = commentID
+	n.UserActionID = c.User.ID
+	n.Reaction = reaction
+	n.Time = float64(time.Now().Unix())
+	(&n).Save(c.Conn)
+}
+
+// removeReactionNotification removes the notification of the reaction if the user was the last one to react
+func removeReactionNotification(c middleware.Context, owner, postID, commentID bson.ObjectId, reaction models.ReactionType) {
+	if owner.Hex() == c.User.ID.Hex() {
+		return
+	}
+
+	query := reactionNotificationQuery(owner, postID, commentID, reaction)
+	query["user_action_id"] = c.User.ID
+	c.RemoveAll("notifications", query)
+}
+
+func reactionNotificationQuery(owner, postID, commentID bson.ObjectId, reaction models.ReactionType) bson.M {
+	query := bson.M{
+		"notification_type": models.NotificationPostLiked,
+		"user_id":           owner,
+		"post_id":           postID,
+		"comment_id":        bson.M{"$exists": false},
+	}
+
+	if commentID.Hex() != "" {
+		query["notification_type"] = models.NotificationCommentReacted
+		query["comment_id"] = commentID
+	}
+
+	// Notifications of likes sent before there were more reactions don't have a reaction
+	if reaction == models.ReactionLike {
+		query["reaction"] = bson.M{"$in": []interface{}{reaction, nil}}
+	} else {
+		query["reaction"] = reaction
+	}
+
+	return query
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/util"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"strconv"
+	"strings"
+)
+
+// CreateReport reports a post or a comment. Every user can only report the same content once and the content
+// is hidden when it reaches a certain number of pending reports until it is reviewed by a moderator.
+//
+// This handler needs the following parameters:
+// - post_id: ID of the reported post or the post of the reported comment
+// - comment_id: ID of the reported comment (optional)
+// - reason: Category of the reason the content is reported for
+// - details: More details about the report (optional)
+func CreateReport(c middleware.Context) {
+	var (
+		post    models.Post
+		comment models.Comment
+	)
+
+	reason, err := strconv.ParseInt(c.Form("reason"), 10, 8)
+	if err != nil || !models.IsValidReportReason(models.ReportReason(reason)) {
+		c.Error(400, CodeInvalidReportReason, MsgInvalidReportReason)
+		return
+	}
+
+	report := models.NewReport(c.User.ID, models.ReportReason(reason))
+	report.Details = strings.TrimSpace(c.Form("details"))
+	if util.Strlen(report.Details) > 500 {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	postID := c.Form("post_id")
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if !(&post).CanBeAccessedBy(c.User, c.Conn) {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	report.PostID = post.ID
+	report.AuthorID = post.UserID
+
+	if commentID := c.Form("comment_id"); commentID != "" {
+		if !bson.IsObjectIdHex(commentID) {
+			c.Error(400, CodeInvalidData, MsgInvalidData)
+			return
+		}
+
+		if err := c.Find("comments", bson.M{"_id": bson.ObjectIdHex(commentID), "post_id": post.ID}).One(&comment); err != nil {
+			c.Error(404, CodeNotFound, MsgNotFound)
+			return
+		}
+
+		report.CommentID = comment.ID
+		report.AuthorID = comment.UserID
+	}
+
+	// Users can't report their own content
+	if report.AuthorID.Hex() == c.User.ID.Hex() {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	query := report.ContentQuery()
+	query["user_id"] = c.User.ID
+	if count, err := c.Count("reports", query); err != nil || count > 0 {
+		c.Error(400, CodeAlreadyReported, MsgAlreadyReported)
+		return
+	}
+
+	// Another request of the user may have reported the content meanwhile
+	if err := report.Save(c.Conn); mgo.IsDup(err) {
+		c.Error(400, CodeAlreadyReported, MsgAlreadyReported)
+		return
+	} else if err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	query = report.ContentQuery()
+	query["status"] = models.ReportPending
+	pending, err := c.Count("reports", query)
+	if err != nil {
+		pending = 0
+	}
+
+	if report.CommentID.Hex() != "" {
+		if pending >= models.ReportsToHide && !comment.Hidden {
+			comment.Hidden = true
+			(&comment).Save(c.Conn)
+		}
+	} else {
+		post.Reported++
+		if pending >= models.ReportsToHide {
+			post.Hidden = true
+		}
+		(&post).Save(c.Conn)
+	}
+
+	c.Success(201, map[string]interface{}{
+		"message": "Content reported successfully",
+	})
+}
+
+// ListReports retrieves the queue of pending reports along with the reported content, oldest first
+func ListReports(c middleware.Context) {
+	count, offset := c.ListCountParams()
+	reports := make([]models.Report, 0, count)
+
+	if err := c.Find("reports", bson.M{"status": models.ReportPending}).Sort("created").Skip(offset).Limit(count).All(&reports); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	for i, r := range reports {
+		if r.CommentID.Hex() != "" {
+			comment := new(models.Comment)
+			if err := c.FindId("comments", r.CommentID).One(comment); err == nil {
+				reports[i].Comment = comment
+			}
+		} else {
+			post := new(models.Post)
+			if err := c.FindId("posts", r.PostID).One(post); err == nil {
+				reports[i].Post = post
+			}
+		}
+	}
+
+	c.Success(200, map[string]interface{}{
+		"reports": reports,
+		"count":   len(reports),
+	})
+}
+
+// ResolveReport resolves all the pending reports of the reported content with an action and notifies the
+// users who reported it of the outcome.
+//
+// This handler needs the following parameters:
+// - action: dismiss to keep the content (and show it again if it was hidden), remove to delete the content
+// or suspend to suspend the account of the author of the content
+func ResolveReport(c middleware.Context, params martini.Params) {
+	var (
+		report models.Report
+		status models.ReportStatus
+	)
+
+	reportID := params["id"]
+	if !bson.IsObjectIdHex(reportID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("reports", bson.ObjectIdHex(reportID)).One(&report); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	switch c.Form("action") {
+	case "dismiss":
+		status = models.ReportDismissed
+		break
+	case "remove":
+		status = models.ReportContentRemoved
+		break
+	case "suspend":
+		status = models.ReportAuthorSuspended
+		break
+	default:
+		c.Error(400, CodeInvalidReportAction, MsgInvalidReportAction)
+		return
+	}
+
+	if report.Status != models.ReportPending {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if err := applyReportAction(c, &report, status); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	if err := jobs.ResolveReports(c, &report, status); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	c.Success(200, map[string]interface{}{
+		"message": "Report resolved successfully",
+	})
+}
+
+// applyReportAction performs on the reported content the action corresponding to the given status
+func applyReportAction(c middleware.Context, report *models.Report, status models.ReportStatus) error {
+	var (
+		post    models.Post
+		comment models.Comment
+	)
+
+	if status == models.ReportAuthorSuspended {
+		return jobs.SuspendUser(c, report.AuthorID)
+	}
+
+	// The content may have already been deleted by its author
+	if report.CommentID.Hex() != "" {
+		if err := c.FindId("comments", report.CommentID).One(&comment); err != nil {
+			return nil
+		}
+
+		if status == models.ReportContentRemoved {
+			return jobs.DeleteComment(c, &comment)
+		}
+
+		comment.Hidden = false
+		return (&comment).Save(c.Conn)
+	}
+
+	if err := c.FindId("posts", report.PostID).One(&post); err != nil {
+		return nil
+	}
+
+	if status == models.ReportContentRemoved {
+		return jobs.DeletePost(c, &post)
+	}
+
+	post.Hidden = false
+	return (&post).Save(c.Conn)
+}
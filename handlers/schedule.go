@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// ListScheduledPosts retrieves the posts of the user that have not been published yet, sorted by publish time.
+// Scheduled posts can be edited with EditPost and ChangePostPrivacy and cancelled with DeletePost.
+func ListScheduledPosts(c middleware.Context) {
+	count, offset := c.ListCountParams()
+	posts := make([]models.Post, 0, count)
+
+	if err := c.Find("posts", bson.M{"user_id": c.User.ID, "scheduled": bson.M{"$gt": 0}}).Sort("scheduled").Skip(offset).Limit(count).All(&posts); err != nil {
+		c.Error(500, CodeUnexpected, MsgUnexpected)
+		return
+	}
+
+	models.SetPostTargets(posts, c.User, c.Conn)
+	models.SetReshareOriginals(posts, c.User, c.Conn)
+
+	c.Success(200, map[string]interface{}{
+		"posts": posts,
+		"count": len(posts),
+	})
+}
+
+// ReschedulePost changes the publish time of a scheduled post owned by the user making the request
+//
+// The following parameters are required:
+// - publish_at: New publish time of the post, 0 to publish it right away
+func ReschedulePost(c middleware.Context, params martini.Params) {
+	var post models.Post
+
+	postID := params["id"]
+	if !bson.IsObjectIdHex(postID) {
+		c.Error(400, CodeInvalidData, MsgInvalidData)
+		return
+	}
+
+	if err := c.FindId("posts", bson.ObjectIdHex(postID)).One(&post); err != nil || post.Scheduled == 0 {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if c.User.ID.Hex() != post.UserID.Hex() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+		return
+	}
+
+	scheduled, err := getPostSchedule(c)
+	if err != nil || c.Form("publish_at") == "" || (!post.ExpiresRelative && post.Expires > 0 && post.Expires <= scheduled) {
+		c.Error(400, CodeInvalidPublishTime, MsgInvalidPublishTime)
+		return
+	}
+
+	now := float64(time.Now().Unix())
+	set := bson.M{"scheduled": scheduled}
+	update := bson.M{"$set": set}
+	if scheduled == 0 {
+		set = bson.M{"created": now}
+		update = bson.M{
+			"$set":   set,
+			"$unset": bson.M{"scheduled": "", "expires_relative": ""},
+		}
+	}
+
+	// Expirations given in seconds and poll durations are counted from the publish time
+	delta := scheduled - post.Scheduled
+	if scheduled == 0 {
+		delta = now - post.Scheduled
+	}
+
+	if post.ExpiresRelative && post.Expires > 0 {
+		post.Expires += delta
+		set["expires"] = post.Expires
+	}
+
+	if post.Poll != nil {
+		post.Poll.Closes += delta
+		set["poll.closes"] = post.Poll.Closes
+	}
+
+	// The post may have been published by the post scheduler meanwhile
+	if err := c.Query("posts").Update(bson.M{"_id": post.ID, "scheduled": post.Scheduled}, update); err != nil {
+		c.Error(404, CodeNotFound, MsgNotFound)
+		return
+	}
+
+	if scheduled == 0 {
+		var target *models.User
+		if post.TargetID.Hex() != "" {
+			target = models.UserExists(c.Conn, post.TargetID)
+		}
+
+		post.Created = now
+		post.Scheduled = 0
+		post.ExpiresRelative = false
+		jobs.PublishPost(c, &post, target)
+	} else {
+		post.Scheduled = scheduled
+	}
+
+	c.Success(200, map[string]interface{}{
+		"message": "Post rescheduled successfully",
+		"post":    post,
+	})
+}
@@ -74,24 +74,36 @@ func GetUserTimeline(c middleware.Context) {
 
 	iter = c.Find("posts", bson.M{"_id": bson.M{"$in": posts}}).Sort("-created").Iter()
 
-	likes := models.GetLikesForPosts(posts, c.User.ID, c.Conn)
+	reactions := models.GetReactionsForPosts(posts, c.User.ID, c.Conn)
 
 	for iter.Next(&p) {
+		// Expired posts may not have been deleted yet and hidden posts are still in the timelines of
+		// the followers of their author
+		if p.Expired() || (p.Hidden && p.UserID.Hex() != c.User.ID.Hex()) {
+			continue
+		}
+
 		if c, ok := comments[p.ID]; ok {
 			p.Comments = c
 		}
 
-		if l, ok := likes[p.ID]; ok {
-			p.Liked = l
-		}
+		p.Reaction = reactions[p.ID]
 
-		if u, ok := udata[p.UserID]; ok {
-			p.User = u
+		// Skip posts of users that have deleted their account
+		u, ok := udata[p.UserID]
+		if !ok {
+			continue
 		}
+		p.User = u
 
 		postsResult = append(postsResult, p)
 	}
 
+	models.SetPostTargets(postsResult, c.User, c.Conn)
+	models.SetReshareOriginals(postsResult, c.User, c.Conn)
+	models.SetPollVotes(postsResult, c.User, c.Conn)
+	models.SetCollapsed(postsResult, c.User)
+
 	c.Success(200, map[string]interface{}{
 		"posts": postsResult,
 		"count": len(postsResult),
@@ -1,6 +1,9 @@
 package middleware
 
-import . "github.com/mvader/sunglasses/error"
+import (
+	. "github.com/mvader/sunglasses/error"
+	"github.com/mvader/sunglasses/models"
+)
 
 // LoginRequired returns an error if the user is not logged in
 func LoginRequired(c Context) {
@@ -9,6 +12,20 @@ func LoginRequired(c Context) {
 	}
 }
 
+// AdminRequired returns an error if the user is not an admin
+func AdminRequired(c Context) {
+	if c.User == nil || c.User.Role != models.RoleAdmin {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+	}
+}
+
+// ModeratorRequired returns an error if the user is not an admin or a moderator
+func ModeratorRequired(c Context) {
+	if c.User == nil || !c.User.CanModerate() {
+		c.Error(403, CodeUnauthorized, MsgUnauthorized)
+	}
+}
+
 // LoginForbidden returns an error if the user is logged in
 func LoginForbidden(c Context) {
 	if c.User != nil {
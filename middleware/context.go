@@ -7,6 +7,7 @@ import (
 	"github.com/martini-contrib/render"
 	"github.com/mvader/sunglasses/models"
 	"github.com/mvader/sunglasses/modules/auth"
+	"github.com/mvader/sunglasses/modules/video"
 	"github.com/mvader/sunglasses/services"
 	. "github.com/mvader/sunglasses/util"
 	"labix.org/v2/mgo"
@@ -25,13 +26,15 @@ type Context struct {
 	Session        *sessions.Session
 	User           *models.User
 	Tasks          *services.TaskService
+	Fetcher        *services.Fetcher
+	Videos         *video.Registry
 	ResponseWriter http.ResponseWriter
 	IsWebToken     bool
 }
 
 // CreateContext initializes the context for a request
-func CreateContext(ctx martini.Context, config *services.Config, conn *services.Connection, render render.Render, r *http.Request, s *sessions.CookieStore, ts *services.TaskService, rw http.ResponseWriter) {
-	c := Context{Config: config, Conn: conn, Request: r, Render: render, ResponseWriter: rw, Tasks: ts}
+func CreateContext(ctx martini.Context, config *services.Config, conn *services.Connection, render render.Render, r *http.Request, s *sessions.CookieStore, ts *services.TaskService, f *services.Fetcher, videos *video.Registry, rw http.ResponseWriter) {
+	c := Context{Config: config, Conn: conn, Request: r, Render: render, ResponseWriter: rw, Tasks: ts, Fetcher: f, Videos: videos}
 
 	if r != nil && s != nil && conn != nil {
 		c.Session, _ = s.Get(r, config.SessionName)
@@ -65,6 +68,17 @@ func (c Context) Errors(status int, codes []int, messages []string) {
 	})
 }
 
+// FileErrors renders a json response with an array of errors for the uploaded files at the given positions
+func (c Context) FileErrors(status int, files, codes []int, messages []string) {
+	c.Render.JSON(status, map[string]interface{}{
+		"error":    true,
+		"single":   false,
+		"messages": messages,
+		"codes":    codes,
+		"files":    files,
+	})
+}
+
 // Success renders a successful JSON response
 func (c Context) Success(status int, data map[string]interface{}) {
 	data["error"] = false
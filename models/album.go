@@ -0,0 +1,82 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// PhotoAlbum model, a collection of photo posts of an user. The privacy of the album constrains the privacy of the
+// photos inside it, a photo can only be accessed if both the album and the photo can be accessed.
+type PhotoAlbum struct {
+	ID          bson.ObjectId          `json:"id" bson:"_id"`
+	User        map[string]interface{} `json:"user,omitempty" bson:"-"`
+	UserID      bson.ObjectId          `json:"-" bson:"user_id"`
+	Title       string                 `json:"title" bson:"title"`
+	Description string                 `json:"description,omitempty" bson:"description,omitempty"`
+	CoverID     bson.ObjectId          `json:"cover_id,omitempty" bson:"cover_id,omitempty"`
+	Cover       string                 `json:"cover,omitempty" bson:"-"`
+	Privacy     PrivacySettings        `json:"privacy" bson:"privacy"`
+	Created     float64                `json:"created" bson:"created"`
+	Updated     float64                `json:"updated" bson:"updated"`
+	PhotosNum   int                    `json:"photos_num" bson:"-"`
+}
+
+// NewAlbum returns a new album instance
+func NewAlbum(user *User) *PhotoAlbum {
+	a := new(PhotoAlbum)
+	a.UserID = user.ID
+	a.Created = float64(time.Now().Unix())
+	a.Updated = a.Created
+
+	return a
+}
+
+// Save inserts the PhotoAlbum instance if it hasn't been created yet or updates it if it has
+func (a *PhotoAlbum) Save(conn interfaces.Saver) error {
+	if a.ID.Hex() == "" {
+		a.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("albums", a.ID, a); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Remove deletes the album
+func (a *PhotoAlbum) Remove(conn interfaces.Remover) error {
+	return conn.Remove("albums", a.ID)
+}
+
+// CanBeAccessedBy determines if the current album can be accessed by the given user
+func (a *PhotoAlbum) CanBeAccessedBy(u *User, conn interfaces.Conn) bool {
+	if a.UserID.Hex() == u.ID.Hex() {
+		return true
+	}
+
+	return a.Privacy.accessibleBy(u.ID, a.UserID, conn)
+}
+
+// LoadDisplayData fills the number of photos and the cover thumbnail of the album. Only the photos the
+// given user can access are counted and the cover is only shown if the user can access it.
+func (a *PhotoAlbum) LoadDisplayData(u *User, conn interfaces.Conn) {
+	var photo Post
+
+	a.PhotosNum = 0
+	a.Cover = ""
+
+	iter := conn.C("posts").Find(bson.M{"album_id": a.ID}).Iter()
+	for iter.Next(&photo) {
+		if !photo.CanBeAccessedBy(u, conn) {
+			continue
+		}
+
+		a.PhotosNum++
+		if photo.ID.Hex() == a.CoverID.Hex() {
+			a.Cover = photo.Thumbnail
+		}
+	}
+	iter.Close()
+}
@@ -47,3 +47,26 @@ func UserIsBlocked(from, to bson.ObjectId, conn interfaces.Conn) bool {
 
 	return count > 0
 }
+
+// BlockedUsers returns the users blocked by the user along with the users who have blocked the user
+func BlockedUsers(user bson.ObjectId, conn interfaces.Conn) ([]bson.ObjectId, error) {
+	var blocks []Block
+
+	if err := conn.C("blocks").Find(bson.M{"$or": []bson.M{
+		bson.M{"user_from": user},
+		bson.M{"user_to": user},
+	}}).All(&blocks); err != nil {
+		return nil, err
+	}
+
+	users := make([]bson.ObjectId, 0, len(blocks))
+	for _, b := range blocks {
+		if b.From.Hex() == user.Hex() {
+			users = append(users, b.To)
+		} else {
+			users = append(users, b.From)
+		}
+	}
+
+	return users, nil
+}
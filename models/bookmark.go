@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// Bookmark model, a post saved by an user. Bookmarks are private and can only be seen by their owner.
+type Bookmark struct {
+	ID     bson.ObjectId `json:"id" bson:"_id"`
+	UserID bson.ObjectId `json:"-" bson:"user_id"`
+	PostID bson.ObjectId `json:"post_id" bson:"post_id"`
+	// Name of the collection the post was saved into, empty if it wasn't saved into any collection
+	Collection string  `json:"collection,omitempty" bson:"collection,omitempty"`
+	Created    float64 `json:"created" bson:"created"`
+	Post       *Post   `json:"post,omitempty" bson:"-"`
+}
+
+// MaxCollectionNameLength is the maximum number of characters of the name of a bookmark collection
+const MaxCollectionNameLength = 50
+
+// NewBookmark returns a new bookmark instance
+func NewBookmark(user, post bson.ObjectId, collection string) *Bookmark {
+	b := new(Bookmark)
+	b.UserID = user
+	b.PostID = post
+	b.Collection = collection
+	b.Created = float64(time.Now().Unix())
+
+	return b
+}
+
+// Save inserts the Bookmark instance if it hasn't been created yet or updates it if it has
+func (b *Bookmark) Save(conn interfaces.Saver) error {
+	if b.ID.Hex() == "" {
+		b.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("bookmarks", b.ID, b); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -6,13 +6,32 @@ import (
 	"time"
 )
 
+// MaxCommentDepth is the maximum number of levels a comment can be nested into replies
+const MaxCommentDepth = 2
+
 type Comment struct {
-	ID      bson.ObjectId          `json:"id" bson:"_id"`
-	UserID  bson.ObjectId          `json:"-" bson:"user_id"`
-	User    map[string]interface{} `json:"user" bson:"-"`
-	PostID  bson.ObjectId          `json:"post_id" bson:"post_id"`
-	Created float64                `json:"created" bson:"created"`
-	Message string                 `json:"message" bson:"message"`
+	ID       bson.ObjectId          `json:"id" bson:"_id"`
+	UserID   bson.ObjectId          `json:"-" bson:"user_id"`
+	User     map[string]interface{} `json:"user" bson:"-"`
+	PostID   bson.ObjectId          `json:"post_id" bson:"post_id"`
+	Created  float64                `json:"created" bson:"created"`
+	Message  string                 `json:"message" bson:"message"`
+	Hashtags []string               `json:"hashtags,omitempty" bson:"hashtags,omitempty"`
+	Mentions []Mention              `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	Hidden   bool                   `json:"hidden,omitempty" bson:"hidden,omitempty"`
+	Edited   bool                   `json:"edited" bson:"edited,omitempty"`
+	EditedAt float64                `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+	// Total number of reactions, the number of each reaction is in Reactions
+	Likes     float64                  `json:"likes" bson:"likes"`
+	Reactions map[ReactionType]float64 `json:"reactions,omitempty" bson:"reactions,omitempty"`
+	// Reaction of the user requesting the comment
+	Reaction ReactionType `json:"reaction,omitempty" bson:"-"`
+	// Comment this comment is a reply to, empty for top-level comments
+	ParentID bson.ObjectId `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	Depth    int           `json:"depth" bson:"depth"`
+	Replies  float64       `json:"replies" bson:"replies"`
+	// Deleted comments that have replies are kept as a placeholder without message and author
+	Deleted bool `json:"deleted,omitempty" bson:"deleted,omitempty"`
 }
 
 // NewComment returns a new instance of Comment
@@ -37,3 +56,29 @@ func (c *Comment) Save(conn interfaces.Saver) error {
 
 	return nil
 }
+
+// NewReply returns a new instance of Comment replying to the given comment
+func NewReply(user bson.ObjectId, parent *Comment) *Comment {
+	c := NewComment(user, parent.PostID)
+	c.ParentID = parent.ID
+	c.Depth = parent.Depth + 1
+
+	return c
+}
+
+// CanBeReplied returns if the comment accepts new replies
+func (c *Comment) CanBeReplied() bool {
+	return !c.Deleted && c.Depth < MaxCommentDepth
+}
+
+// SetEntities sets the hashtags and mentions of the comment from its message
+func (c *Comment) SetEntities(conn interfaces.Conn) {
+	c.Hashtags = ParseHashtags(c.Message)
+	c.Mentions = ParseMentions(conn, c.Message)
+}
+
+// VisibleTo returns if the comment can be displayed to the given user. Hidden comments can only be
+// displayed to their author until they are reviewed.
+func (c *Comment) VisibleTo(u *User) bool {
+	return c.Deleted || !c.Hidden || c.UserID.Hex() == u.ID.Hex()
+}
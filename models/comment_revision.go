@@ -0,0 +1,49 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
+
+// CommentRevision model, it keeps the message a comment had before it was edited
+type CommentRevision struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	CommentID bson.ObjectId `json:"comment_id" bson:"comment_id"`
+	PostID    bson.ObjectId `json:"post_id" bson:"post_id"`
+	UserID    bson.ObjectId `json:"-" bson:"user_id"`
+	Message   string        `json:"message" bson:"message"`
+	// Time at which the comment got this message, the creation time for the first revision
+	Created float64 `json:"created" bson:"created"`
+	// Time at which the message was replaced by an edit
+	Replaced float64 `json:"replaced" bson:"replaced"`
+}
+
+// NewCommentRevision returns a revision with the current message of the given comment
+func NewCommentRevision(c *Comment, replaced float64) *CommentRevision {
+	r := new(CommentRevision)
+	r.CommentID = c.ID
+	r.PostID = c.PostID
+	r.UserID = c.UserID
+	r.Message = c.Message
+	r.Created = c.Created
+	r.Replaced = replaced
+
+	if c.EditedAt > 0 {
+		r.Created = c.EditedAt
+	}
+
+	return r
+}
+
+// Save inserts the CommentRevision instance if it hasn't been created yet or updates it if it has
+func (r *CommentRevision) Save(conn interfaces.Saver) error {
+	if r.ID.Hex() == "" {
+		r.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("comment_revisions", r.ID, r); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// Draft model, it keeps the content of a post that has not been published yet. The photos of photo drafts
+// are already stored and are kept until the draft is published or removed.
+type Draft struct {
+	ID       bson.ObjectId `json:"id" bson:"_id"`
+	UserID   bson.ObjectId `json:"-" bson:"user_id"`
+	TargetID bson.ObjectId `json:"target_user_id,omitempty" bson:"target_id,omitempty"`
+	Type     ObjectType    `json:"post_type" bson:"post_type"`
+	Text     string        `json:"text,omitempty" bson:"text,omitempty"`
+	// Content warning shown instead of the post until the user expands it
+	ContentWarning string `json:"content_warning,omitempty" bson:"content_warning,omitempty"`
+	Sensitive      bool   `json:"sensitive,omitempty" bson:"sensitive,omitempty"`
+	// URL of the video or the link
+	URL string `json:"post_url,omitempty" bson:"post_url,omitempty"`
+	// Post to reshare, only for reshares
+	PostID  bson.ObjectId `json:"post_id,omitempty" bson:"post_id,omitempty"`
+	AlbumID bson.ObjectId `json:"album_id,omitempty" bson:"album_id,omitempty"`
+	Photos  []Photo       `json:"photos,omitempty" bson:"photos,omitempty"`
+	// Options of the poll in order, only for polls. A duration of 0 means the default one will be used
+	PollOptions  []string `json:"poll_options,omitempty" bson:"poll_options,omitempty"`
+	PollMultiple bool     `json:"poll_multiple,omitempty" bson:"poll_multiple,omitempty"`
+	PollDuration int64    `json:"poll_duration,omitempty" bson:"poll_duration,omitempty"`
+	// Privacy type 0 means the default privacy settings of the user will be used
+	Privacy PrivacySettings `json:"privacy" bson:"privacy"`
+	Created float64         `json:"created" bson:"created"`
+	Updated float64         `json:"updated" bson:"updated"`
+}
+
+// NewDraft returns a new draft instance
+func NewDraft(t ObjectType, user *User) *Draft {
+	d := new(Draft)
+	d.Type = t
+	d.UserID = user.ID
+	d.Created = float64(time.Now().Unix())
+	d.Updated = d.Created
+
+	return d
+}
+
+// Save inserts the Draft instance if it hasn't been created yet or updates it if it has
+func (d *Draft) Save(conn interfaces.Saver) error {
+	if d.ID.Hex() == "" {
+		d.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("drafts", d.ID, d); err != nil {
+		return err
+	}
+
+	return nil
+}
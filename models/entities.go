@@ -0,0 +1,87 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	hashtagRegexp = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#&])#([\p{L}\p{N}_]{1,100})`)
+	mentionRegexp = regexp.MustCompile(`(?:^|[^a-zA-Z0-9_@])@([a-zA-Z0-9_]{2,30})`)
+)
+
+// Mention is an user mentioned with @username in a post or a comment
+type Mention struct {
+	UserID   bson.ObjectId `json:"user_id" bson:"user_id"`
+	Username string        `json:"username" bson:"username"`
+}
+
+// ParseHashtags returns the hashtags in the given texts in lowercase and without the leading #.
+// Hashtags made only of numbers are ignored.
+func ParseHashtags(texts ...string) []string {
+	var (
+		hashtags []string
+		seen     = make(map[string]bool)
+	)
+
+	for _, text := range texts {
+		for _, match := range hashtagRegexp.FindAllStringSubmatch(text, -1) {
+			tag := strings.ToLower(match[1])
+			if seen[tag] || strings.IndexFunc(tag, unicode.IsLetter) < 0 {
+				continue
+			}
+
+			seen[tag] = true
+			hashtags = append(hashtags, tag)
+		}
+	}
+
+	return hashtags
+}
+
+// ParseMentions returns the mentions in the given texts of users that exist and are active
+func ParseMentions(conn interfaces.Conn, texts ...string) []Mention {
+	var (
+		usernames []string
+		seen      = make(map[string]bool)
+		users     []User
+	)
+
+	for _, text := range texts {
+		for _, match := range mentionRegexp.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				usernames = append(usernames, match[1])
+			}
+		}
+	}
+
+	if len(usernames) == 0 {
+		return nil
+	}
+
+	if err := conn.C("users").Find(bson.M{"username": bson.M{"$in": usernames}, "active": true}).All(&users); err != nil {
+		return nil
+	}
+
+	mentions := make([]Mention, 0, len(users))
+	for _, u := range users {
+		mentions = append(mentions, Mention{UserID: u.ID, Username: u.Username})
+	}
+
+	return mentions
+}
+
+// IsMentioned returns if the user is in the list of mentions
+func IsMentioned(mentions []Mention, user bson.ObjectId) bool {
+	for _, m := range mentions {
+		if m.UserID.Hex() == user.Hex() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// JobType is the type of a background job
+type JobType int
+
+// JobStatus is the status of a background job
+type JobStatus int
+
+const (
+	// Job types
+	JobPostsPrivacyChange = 1
+	JobDataExport         = 2
+
+	// Job statuses
+	JobRunning  = 1
+	JobFinished = 2
+	JobFailed   = 3
+
+	// Expiration time of the data exports
+	ExportExpirationHours = 48
+)
+
+// Job model, it keeps track of the progress of a background job started by an user
+type Job struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	UserID    bson.ObjectId `json:"-" bson:"user_id"`
+	Type      JobType       `json:"job_type" bson:"job_type"`
+	Status    JobStatus     `json:"status" bson:"status"`
+	Total     int           `json:"total" bson:"total"`
+	Processed int           `json:"processed" bson:"processed"`
+	Failed    int           `json:"failed" bson:"failed"`
+	Created   float64       `json:"created" bson:"created"`
+	Finished  float64       `json:"finished,omitempty" bson:"finished,omitempty"`
+
+	// Data export specific fields
+	File    string  `json:"-" bson:"file,omitempty"`
+	Expires float64 `json:"expires,omitempty" bson:"expires,omitempty"`
+}
+
+// NewJob returns a new running job for the given user
+func NewJob(t JobType, user bson.ObjectId) *Job {
+	j := new(Job)
+	j.Type = t
+	j.UserID = user
+	j.Status = JobRunning
+	j.Created = float64(time.Now().Unix())
+
+	return j
+}
+
+// Save inserts the Job instance if it hasn't been created yet or updates it if it has
+func (j *Job) Save(conn interfaces.Saver) error {
+	if j.ID.Hex() == "" {
+		j.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("jobs", j.ID, j); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Expired returns if the file generated by the job is not available anymore
+func (j *Job) Expired() bool {
+	return j.Expires > 0 && j.Expires <= float64(time.Now().Unix())
+}
+
+// Finish marks the job as finished (or failed) and notifies the user
+func (j *Job) Finish(failed bool, conn interfaces.Saver) error {
+	j.Status = JobFinished
+	if failed {
+		j.Status = JobFailed
+	}
+	j.Finished = float64(time.Now().Unix())
+
+	if err := j.Save(conn); err != nil {
+		return err
+	}
+
+	n := Notification{}
+	n.Type = NotificationJobFinished
+	n.User = j.UserID
+	n.JobID = j.ID
+	n.Time = j.Finished
+
+	return n.Save(conn)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
+
+// LinkPreview model, the metadata of a linked page shown along with link posts.
+// Previews are cached per URL in their own collection and copied into the posts linking them.
+type LinkPreview struct {
+	ID bson.ObjectId `json:"-" bson:"_id"`
+	// URL that was posted, used as the cache key
+	URL            string  `json:"-" bson:"url"`
+	CanonicalURL   string  `json:"url" bson:"canonical_url"`
+	Title          string  `json:"title" bson:"title"`
+	Description    string  `json:"description,omitempty" bson:"description,omitempty"`
+	SiteName       string  `json:"site_name,omitempty" bson:"site_name,omitempty"`
+	Image          string  `json:"image,omitempty" bson:"image,omitempty"`
+	ImageThumbnail string  `json:"image_thumbnail,omitempty" bson:"image_thumbnail,omitempty"`
+	Fetched        float64 `json:"-" bson:"fetched"`
+}
+
+// Save inserts the LinkPreview instance if it hasn't been created yet or updates it if it has
+func (p *LinkPreview) Save(conn interfaces.Saver) error {
+	if p.ID.Hex() == "" {
+		p.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("link_previews", p.ID, p); err != nil {
+		return err
+	}
+
+	return nil
+}
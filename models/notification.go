@@ -14,9 +14,13 @@ type Notification struct {
 	ID           bson.ObjectId          `json:"id" bson:"_id"`
 	Type         NotificationType       `json:"notification_type" bson:"notification_type"`
 	PostID       bson.ObjectId          `json:"post_id" bson:"post_id,omitempty"`
+	CommentID    bson.ObjectId          `json:"comment_id,omitempty" bson:"comment_id,omitempty"`
 	User         bson.ObjectId          `json:"user_id" bson:"user_id"`
 	UserActionID bson.ObjectId          `json:"-" bson:"user_action_id,omitempty"`
 	UserAction   map[string]interface{} `json:"user_action" bson:"-"`
+	JobID        bson.ObjectId          `json:"job_id,omitempty" bson:"job_id,omitempty"`
+	ReportID     bson.ObjectId          `json:"report_id,omitempty" bson:"report_id,omitempty"`
+	Reaction     ReactionType           `json:"reaction,omitempty" bson:"reaction,omitempty"`
 	Time         float64                `json:"time" bson:"time"`
 	Read         bool                   `json:"read" bson:"read"`
 }
@@ -28,6 +32,13 @@ const (
 	NotificationPostLiked             = 4
 	NotificationPostCommented         = 5
 	NotificationPostOnMyWall          = 6
+	NotificationJobFinished           = 7
+	NotificationReportResolved        = 8
+	NotificationMentioned             = 9
+	NotificationPostReshared          = 10
+	NotificationCommentReacted        = 11
+	NotificationPollClosed            = 12
+	NotificationCommentReplied        = 13
 )
 
 // Save inserts the Notification instance if it hasn't been created yet or updates it if it has
@@ -52,6 +63,12 @@ func SendNotification(notificationType NotificationType, user *User, postID, use
 		}
 		break
 
+	case NotificationCommentReacted:
+		if !user.Settings.NotifyLikes || user.Settings.MuteCommentLikes {
+			return nil
+		}
+		break
+
 	case NotificationPostCommented:
 		if !user.Settings.NotifyNewComment {
 			return nil
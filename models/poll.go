@@ -0,0 +1,131 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+const (
+	// MinPollOptions and MaxPollOptions are the number of options a poll can have
+	MinPollOptions = 2
+	MaxPollOptions = 10
+	// MaxPollOptionLength is the maximum number of characters of a poll option
+	MaxPollOptionLength = 100
+	// MinPollDuration and MaxPollDuration are the number of seconds a poll can be open
+	MinPollDuration = 5 * 60
+	MaxPollDuration = 30 * 24 * 3600
+	// DefaultPollDuration is the number of seconds a poll is open if its closing time is not given
+	DefaultPollDuration = 24 * 3600
+)
+
+// Poll is the content of a poll post. The results of the poll are only shown to users who have voted
+// and to the author of the poll until it closes.
+type Poll struct {
+	Options  []PollOption `json:"options" bson:"options"`
+	Multiple bool         `json:"multiple" bson:"multiple"`
+	// Time at which the poll closes
+	Closes float64 `json:"closes" bson:"closes"`
+	// Closed is set once the author has been notified about the closing of the poll
+	Closed bool    `json:"closed" bson:"closed"`
+	Voters float64 `json:"voters" bson:"voters"`
+
+	// Options voted by the user requesting the poll, nil if the user has not voted
+	Voted         []int `json:"voted,omitempty" bson:"-"`
+	ResultsHidden bool  `json:"results_hidden,omitempty" bson:"-"`
+}
+
+// PollOption is one of the options of a poll
+type PollOption struct {
+	Text  string  `json:"text" bson:"text"`
+	Votes float64 `json:"votes" bson:"votes"`
+}
+
+// PollVote is the vote of an user in a poll, each user can only vote once in a poll
+type PollVote struct {
+	ID      bson.ObjectId `json:"id" bson:"_id"`
+	PostID  bson.ObjectId `json:"post_id" bson:"post_id"`
+	UserID  bson.ObjectId `json:"-" bson:"user_id"`
+	Options []int         `json:"options" bson:"options"`
+	Created float64       `json:"created" bson:"created"`
+}
+
+// NewPoll returns a new poll with the given options that closes at the given time
+func NewPoll(options []string, multiple bool, closes float64) *Poll {
+	p := new(Poll)
+	p.Multiple = multiple
+	p.Closes = closes
+	p.Options = make([]PollOption, len(options))
+	for i, o := range options {
+		p.Options[i].Text = o
+	}
+
+	return p
+}
+
+// IsClosed returns if the poll does not accept more votes
+func (p *Poll) IsClosed() bool {
+	return p.Closed || p.Closes <= float64(time.Now().Unix())
+}
+
+// IsValidVote returns if the given options can be voted in the poll
+func (p *Poll) IsValidVote(options []int) bool {
+	if len(options) < 1 || (!p.Multiple && len(options) > 1) {
+		return false
+	}
+
+	voted := make(map[int]bool)
+	for _, o := range options {
+		if o < 0 || o >= len(p.Options) || voted[o] {
+			return false
+		}
+
+		voted[o] = true
+	}
+
+	return true
+}
+
+// SetPollVotes sets the options voted by the user in the polls of the given posts and the originals of
+// the reshares, hiding the results of the open polls the user has not voted in
+func SetPollVotes(posts []Post, user *User, conn interfaces.Conn) {
+	var (
+		polls = make([]*Post, 0, len(posts))
+		ids   = make([]bson.ObjectId, 0, len(posts))
+		votes []PollVote
+	)
+
+	for i := range posts {
+		for _, p := range []*Post{&posts[i], posts[i].Original} {
+			if p != nil && p.Poll != nil {
+				polls = append(polls, p)
+				ids = append(ids, p.ID)
+			}
+		}
+	}
+
+	if len(polls) == 0 {
+		return
+	}
+
+	if err := conn.C("poll_votes").Find(bson.M{"post_id": bson.M{"$in": ids}, "user_id": user.ID}).All(&votes); err != nil {
+		votes = nil
+	}
+
+	for _, p := range polls {
+		for _, v := range votes {
+			if v.PostID.Hex() == p.ID.Hex() {
+				p.Poll.Voted = v.Options
+				break
+			}
+		}
+
+		if p.Poll.Voted == nil && !p.Poll.IsClosed() && p.UserID.Hex() != user.ID.Hex() {
+			p.Poll.ResultsHidden = true
+			p.Poll.Voters = 0
+			for i := range p.Poll.Options {
+				p.Poll.Options[i].Votes = 0
+			}
+		}
+	}
+}
@@ -10,11 +10,13 @@ type ObjectType int
 
 const (
 	// Post types
-	PostStatus = 1
-	PostPhoto  = 2
-	PostVideo  = 3
-	PostLink   = 4
-	Album      = 5
+	PostStatus  = 1
+	PostPhoto   = 2
+	PostVideo   = 3
+	PostLink    = 4
+	Album       = 5
+	PostReshare = 6
+	PostPoll    = 7
 )
 
 // Post model
@@ -22,39 +24,85 @@ type Post struct {
 	ID          bson.ObjectId          `json:"id" bson:"_id"`
 	User        map[string]interface{} `json:"user" bson:"-"`
 	UserID      bson.ObjectId          `json:"-" bson:"user_id"`
+	Target      map[string]interface{} `json:"target,omitempty" bson:"-"`
+	TargetID    bson.ObjectId          `json:"-" bson:"target_id,omitempty"`
 	Created     float64                `json:"created" bson:"created"`
 	Type        ObjectType             `json:"post_type" bson:"post_type"`
 	Likes       float64                `json:"likes" bson:"likes"`
 	CommentsNum float64                `json:"comments_num" bson:"comments_num"`
 	Comments    []Comment              `json:"comments" bson:"-"`
 	Reported    float64                `json:"reported" bson:"reported"`
+	Hidden      bool                   `json:"hidden,omitempty" bson:"hidden,omitempty"`
 	Privacy     PrivacySettings        `json:"privacy" bson:"privacy"`
 	Text        string                 `json:"text,omitempty" bson:"text,omitempty"`
-	Liked       bool                   `json:"liked,omitempty" bson:"-"`
+	Hashtags    []string               `json:"hashtags,omitempty" bson:"hashtags,omitempty"`
+	Mentions    []Mention              `json:"mentions,omitempty" bson:"mentions,omitempty"`
+	// Short warning shown instead of the content of the post until it is expanded
+	ContentWarning string `json:"content_warning,omitempty" bson:"content_warning,omitempty"`
+	// Sensitive is true if the media of the post must be blurred until it is expanded
+	Sensitive bool `json:"sensitive" bson:"sensitive,omitempty"`
+	// Collapsed is true if the post must be collapsed for the user requesting it
+	Collapsed bool `json:"collapsed" bson:"-"`
+	// Time at which the post will be deleted, 0 if the post does not expire
+	Expires float64 `json:"expires,omitempty" bson:"expires,omitempty"`
+	// Time at which a scheduled post will be published, 0 once the post has been published
+	Scheduled float64 `json:"scheduled,omitempty" bson:"scheduled,omitempty"`
+	// ExpiresRelative is set if the expiration of a scheduled post is counted from its publish time
+	ExpiresRelative bool `json:"-" bson:"expires_relative,omitempty"`
+	// Time at which the post was pinned to the top of the author's profile, 0 if it is not pinned
+	Pinned   float64 `json:"pinned,omitempty" bson:"pinned,omitempty"`
+	Edited   bool    `json:"edited" bson:"edited,omitempty"`
+	EditedAt float64 `json:"edited_at,omitempty" bson:"edited_at,omitempty"`
+
+	// Number of each reaction, Likes is the total number of reactions. Reaction is the one of the user
+	// requesting the post, if any
+	Reactions map[ReactionType]float64 `json:"reactions,omitempty" bson:"reactions,omitempty"`
+	Reaction  ReactionType             `json:"reaction,omitempty" bson:"-"`
 
 	// Video specific fields
-	Service VideoService `json:"video_service,omitempty" bson:"video_service,omitempty"`
-	VideoID string       `json:"video_id,omitempty" bson:"video_id,omitempty"`
+	Service        VideoService `json:"video_service,omitempty" bson:"video_service,omitempty"`
+	VideoID        string       `json:"video_id,omitempty" bson:"video_id,omitempty"`
+	VideoProvider  string       `json:"video_provider,omitempty" bson:"video_provider,omitempty"`
+	EmbedURL       string       `json:"embed_url,omitempty" bson:"embed_url,omitempty"`
+	VideoThumbnail string       `json:"video_thumbnail,omitempty" bson:"video_thumbnail,omitempty"`
+	// Duration of the video in seconds, 0 if the provider does not give it
+	VideoDuration float64 `json:"video_duration,omitempty" bson:"video_duration,omitempty"`
 	// Also used in link
 	Title string `json:"title,omitempty" bson:"title,omitempty"`
 
-	// Photo specific fields
+	// Photo specific fields, PhotoURL, Caption and Thumbnail are the ones of the first photo
 	PhotoURL  string        `json:"photo_url,omitempty" bson:"photo_url,omitempty"`
 	Caption   string        `json:"caption,omitempty" bson:"caption,omitempty"`
 	AlbumID   bson.ObjectId `json:"album_id,omitempty" bson:"album_id,omitempty"`
 	Thumbnail string        `json:"thumbnail,omitempty" bson:"thumbnail,omitempty"`
+	Photos    []Photo       `json:"photos,omitempty" bson:"photos,omitempty"`
+
+	// Reshare specific fields, Original is only used for display
+	ReshareOf bson.ObjectId `json:"reshare_of,omitempty" bson:"reshare_of,omitempty"`
+	Original  *Post         `json:"original,omitempty" bson:"-"`
+	Reshares  float64       `json:"reshares" bson:"reshares"`
 
 	// Link specific fields
-	URL string `json:"link_url,omitempty" bson:"link_url,omitempty"`
+	URL     string       `json:"link_url,omitempty" bson:"link_url,omitempty"`
+	Preview *LinkPreview `json:"preview,omitempty" bson:"preview,omitempty"`
+
+	// Poll specific fields
+	Poll *Poll `json:"poll,omitempty" bson:"poll,omitempty"`
 }
 
-// PostLike model
-type PostLike struct {
-	ID     bson.ObjectId `json:"id" bson:"_id"`
-	UserID bson.ObjectId `json:"user_id" bson:"user_id"`
-	PostID bson.ObjectId `json:"post_id" bson:"post_id"`
+// Photo is one of the images of a photo post
+type Photo struct {
+	URL       string `json:"photo_url" bson:"photo_url"`
+	Thumbnail string `json:"thumbnail" bson:"thumbnail"`
+	Caption   string `json:"caption,omitempty" bson:"caption,omitempty"`
 }
 
+// MaxPostPhotos is the maximum number of photos a photo post can have
+const MaxPostPhotos = 20
+
+// MaxPinnedPosts is the maximum number of posts an user can have pinned to their profile
+const MaxPinnedPosts = 3
+
 // NewPost returns a new post instance
 func NewPost(t ObjectType, user *User) *Post {
 	p := new(Post)
@@ -78,65 +126,134 @@ func (p *Post) Save(conn interfaces.Saver) error {
 	return nil
 }
 
+// Images returns all the photos of a photo post in order. Posts created before photo posts could have
+// more than one photo only have the PhotoURL and Thumbnail fields.
+func (p *Post) Images() []Photo {
+	if len(p.Photos) > 0 {
+		return p.Photos
+	}
+
+	if p.PhotoURL != "" {
+		return []Photo{Photo{URL: p.PhotoURL, Thumbnail: p.Thumbnail, Caption: p.Caption}}
+	}
+
+	return nil
+}
+
+// SetEntities sets the hashtags and mentions of the post from its text and the captions of its photos
+func (p *Post) SetEntities(conn interfaces.Conn) {
+	texts := []string{p.Text}
+	for _, photo := range p.Images() {
+		texts = append(texts, photo.Caption)
+	}
+
+	p.Hashtags = ParseHashtags(texts...)
+	p.Mentions = ParseMentions(conn, texts...)
+}
+
+// Expired returns if the post has expired and thus is pending to be deleted
+func (p *Post) Expired() bool {
+	return p.Expires > 0 && p.Expires <= float64(time.Now().Unix())
+}
+
+// CanBePinned returns if the post can be pinned to the profile of its author. Only published posts in the
+// author's own profile that can be accessed by someone other than the author can be pinned.
+func (p *Post) CanBePinned(conn interfaces.Conn) bool {
+	if p.Expired() || p.Scheduled > 0 || (p.TargetID.Hex() != "" && p.TargetID.Hex() != p.UserID.Hex()) {
+		return false
+	}
+
+	if int(p.Privacy.Type) == PrivacyNone {
+		return false
+	}
+
+	if p.AlbumID.Hex() != "" {
+		var album PhotoAlbum
+		if err := conn.C("albums").FindId(p.AlbumID).One(&album); err == nil && int(album.Privacy.Type) == PrivacyNone {
+			return false
+		}
+	}
+
+	return true
+}
+
 // CanBeAccessedBy determines if the current post can be accessed by the given user
 func (p *Post) CanBeAccessedBy(u *User, conn interfaces.Conn) bool {
-	if p.UserID.Hex() == u.ID.Hex() {
-		return true
+	// Scheduled posts can't be accessed by anyone until they are published
+	if p.Expired() || p.Scheduled > 0 {
+		return false
+	}
+
+	// Hidden posts can only be accessed by their author until they are reviewed
+	if p.Hidden && p.UserID.Hex() != u.ID.Hex() {
+		return false
 	}
 
-	inUsersArray := false
-	for _, i := range p.Privacy.Users {
-		if i.Hex() == u.ID.Hex() {
-			inUsersArray = true
-			break
+	// Reshares can only be accessed by users that can access the original post
+	if p.ReshareOf.Hex() != "" {
+		var original Post
+		if err := conn.C("posts").FindId(p.ReshareOf).One(&original); err != nil || !original.CanBeAccessedBy(u, conn) {
+			return false
 		}
 	}
 
-	switch int(p.Privacy.Type) {
-	case PrivacyPublic:
+	// The owner of the profile the post was posted in can always access it
+	if p.UserID.Hex() == u.ID.Hex() || p.TargetID.Hex() == u.ID.Hex() {
 		return true
-	case PrivacyNone:
-		return false
-	case PrivacyFollowersOnly:
-		return Follows(u.ID, p.UserID, conn)
-	case PrivacyFollowingOnly:
-		return FollowedBy(u.ID, p.UserID, conn)
-	case PrivacyAllBut:
-		return !inUsersArray
-	case PrivacyNoneBut:
-		return inUsersArray
-	case PrivacyFollowersBut:
-		return Follows(u.ID, p.UserID, conn) && !inUsersArray
-	case PrivacyFollowingBut:
-		return FollowedBy(u.ID, p.UserID, conn) && !inUsersArray
-	}
-
-	return false
+	}
+
+	// Photos inside an album can't be accessed if the album can't be accessed
+	if p.AlbumID.Hex() != "" {
+		var album PhotoAlbum
+		if err := conn.C("albums").FindId(p.AlbumID).One(&album); err == nil && !album.CanBeAccessedBy(u, conn) {
+			return false
+		}
+	}
+
+	return p.Privacy.accessibleBy(u.ID, p.UserID, conn)
 }
 
-// GetLikesForPosts gets all user likes for a list of posts
-func GetLikesForPosts(posts []bson.ObjectId, user bson.ObjectId, conn interfaces.Conn) map[bson.ObjectId]bool {
-	var like PostLike
-	result := make(map[bson.ObjectId]bool)
+// SetCollapsed marks the given posts and the originals of the reshares as collapsed if they have a content
+// warning or sensitive media and the user does not want to expand them. Posts of the user are never collapsed.
+func SetCollapsed(posts []Post, user *User) {
+	for i := range posts {
+		for _, p := range []*Post{&posts[i], posts[i].Original} {
+			if p != nil {
+				p.Collapsed = (p.Sensitive || p.ContentWarning != "") &&
+					!user.Settings.ExpandSensitiveContent &&
+					p.UserID.Hex() != user.ID.Hex()
+			}
+		}
+	}
+}
 
-	for _, v := range posts {
-		result[v] = false
+// SetPostTargets fills the data of the users whose profiles the given posts were posted in
+func SetPostTargets(posts []Post, user *User, conn interfaces.Conn) {
+	ids := make([]bson.ObjectId, 0, len(posts))
+	for _, p := range posts {
+		if p.TargetID.Hex() != "" {
+			ids = append(ids, p.TargetID)
+		}
 	}
 
-	iter := conn.C("likes").Find(bson.M{"post_id": bson.M{"$in": posts}, "user_id": user}).Iter()
-	for iter.Next(&like) {
-		result[like.PostID] = true
+	if len(ids) == 0 {
+		return
 	}
 
-	return result
+	data := GetUsersData(ids, user, conn)
+	for i, p := range posts {
+		if u, ok := data[p.TargetID]; ok {
+			posts[i].Target = u
+		}
+	}
 }
 
-// GetCommentsForPost returns up to N comments for the given post
+// GetCommentsForPost returns up to N top-level comments for the given post
 func GetCommentsForPost(post bson.ObjectId, user *User, n int, conn interfaces.Conn) []Comment {
 	uids := make([]bson.ObjectId, 0, n)
 	var comments []Comment
 
-	iter := conn.C("comments").Find(bson.M{"post_id": post}).Limit(n).Sort("created").Iter()
+	iter := conn.C("comments").Find(bson.M{"post_id": post, "parent_id": bson.M{"$exists": false}}).Limit(n).Sort("created").Iter()
 	err := iter.All(&comments)
 	if err != nil {
 		return nil
@@ -151,9 +268,25 @@ func GetCommentsForPost(post bson.ObjectId, user *User, n int, conn interfaces.C
 		return nil
 	}
 
+	// Comments of deleted users and hidden comments of other users are not displayed
+	result := make([]Comment, 0, len(comments))
 	for i, _ := range comments {
-		comments[i].User = data[comments[i].UserID]
+		if !comments[i].VisibleTo(user) {
+			continue
+		}
+
+		if comments[i].Deleted {
+			result = append(result, comments[i])
+			continue
+		}
+
+		if u, ok := data[comments[i].UserID]; ok {
+			comments[i].User = u
+			result = append(result, comments[i])
+		}
 	}
 
-	return comments
+	SetCommentReactions(result, user.ID, conn)
+
+	return result
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
+
+// PostRevision model, it keeps the content a post had before it was edited
+type PostRevision struct {
+	ID       bson.ObjectId `json:"id" bson:"_id"`
+	PostID   bson.ObjectId `json:"post_id" bson:"post_id"`
+	UserID   bson.ObjectId `json:"-" bson:"user_id"`
+	Text     string        `json:"text,omitempty" bson:"text,omitempty"`
+	Caption  string        `json:"caption,omitempty" bson:"caption,omitempty"`
+	Captions []string      `json:"captions,omitempty" bson:"captions,omitempty"`
+	// Time at which the post got this content, the creation time for the first revision
+	Created float64 `json:"created" bson:"created"`
+	// Time at which the content was replaced by an edit
+	Replaced float64 `json:"replaced" bson:"replaced"`
+}
+
+// NewPostRevision returns a revision with the current content of the given post
+func NewPostRevision(p *Post, replaced float64) *PostRevision {
+	r := new(PostRevision)
+	r.PostID = p.ID
+	r.UserID = p.UserID
+	r.Text = p.Text
+	r.Caption = p.Caption
+	r.Created = p.Created
+	r.Replaced = replaced
+
+	if p.EditedAt > 0 {
+		r.Created = p.EditedAt
+	}
+
+	if len(p.Photos) > 1 {
+		r.Captions = make([]string, 0, len(p.Photos))
+		for _, photo := range p.Photos {
+			r.Captions = append(r.Captions, photo.Caption)
+		}
+	}
+
+	return r
+}
+
+// Save inserts the PostRevision instance if it hasn't been created yet or updates it if it has
+func (r *PostRevision) Save(conn interfaces.Saver) error {
+	if r.ID.Hex() == "" {
+		r.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("post_revisions", r.ID, r); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -1,6 +1,9 @@
 package models
 
-import "labix.org/v2/mgo/bson"
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
 
 type PrivacyType int
 
@@ -33,3 +36,51 @@ func NewPrivacySettings() PrivacySettings {
 func IsValidPrivacyType(t PrivacyType) bool {
 	return t > 0 && t <= PrivacyNoneBut
 }
+
+// CanBeAccessedBy determines if the given user can access an object protected by these privacy settings.
+// follows must be true if the user follows the owner of the object and followedBy if the owner follows the user.
+func (p PrivacySettings) CanBeAccessedBy(userID bson.ObjectId, follows, followedBy bool) bool {
+	inUsersArray := false
+	for _, i := range p.Users {
+		if i.Hex() == userID.Hex() {
+			inUsersArray = true
+			break
+		}
+	}
+
+	switch int(p.Type) {
+	case PrivacyPublic:
+		return true
+	case PrivacyNone:
+		return false
+	case PrivacyFollowersOnly:
+		return follows
+	case PrivacyFollowingOnly:
+		return followedBy
+	case PrivacyAllBut:
+		return !inUsersArray
+	case PrivacyNoneBut:
+		return inUsersArray
+	case PrivacyFollowersBut:
+		return follows && !inUsersArray
+	case PrivacyFollowingBut:
+		return followedBy && !inUsersArray
+	}
+
+	return false
+}
+
+// accessibleBy determines if the given user can access an object of the owner protected by these privacy
+// settings. The follows between them are only looked up if the privacy type depends on them.
+func (p PrivacySettings) accessibleBy(userID, ownerID bson.ObjectId, conn interfaces.Conn) bool {
+	var follows, followedBy bool
+
+	switch int(p.Type) {
+	case PrivacyFollowersOnly, PrivacyFollowersBut:
+		follows = Follows(userID, ownerID, conn)
+	case PrivacyFollowingOnly, PrivacyFollowingBut:
+		followedBy = FollowedBy(userID, ownerID, conn)
+	}
+
+	return p.CanBeAccessedBy(userID, follows, followedBy)
+}
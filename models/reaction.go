@@ -0,0 +1,106 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
+
+// ReactionType is the kind of reaction of an user to a post or a comment
+type ReactionType string
+
+const (
+	ReactionLike  ReactionType = "like"
+	ReactionLove  ReactionType = "love"
+	ReactionHaha  ReactionType = "haha"
+	ReactionWow   ReactionType = "wow"
+	ReactionSad   ReactionType = "sad"
+	ReactionAngry ReactionType = "angry"
+)
+
+// ReactionTypes are all the reactions users can have to posts and comments
+var ReactionTypes = []ReactionType{ReactionLike, ReactionLove, ReactionHaha, ReactionWow, ReactionSad, ReactionAngry}
+
+// Reaction model, reactions are stored in the likes collection. Likes stored before there were more
+// reactions than likes don't have a type and are treated as ReactionLike.
+type Reaction struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	UserID    bson.ObjectId `json:"user_id" bson:"user_id"`
+	PostID    bson.ObjectId `json:"post_id" bson:"post_id"`
+	CommentID bson.ObjectId `json:"comment_id,omitempty" bson:"comment_id,omitempty"`
+	Type      ReactionType  `json:"reaction" bson:"reaction,omitempty"`
+}
+
+// IsValidReaction returns if the given reaction is one of the available reactions
+func IsValidReaction(r ReactionType) bool {
+	for _, t := range ReactionTypes {
+		if t == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Kind returns the type of the reaction
+func (r *Reaction) Kind() ReactionType {
+	if r.Type == "" {
+		return ReactionLike
+	}
+
+	return r.Type
+}
+
+// ReactionQuery returns the query that matches the reactions to the post or, if it's given, to the comment
+func ReactionQuery(post, comment bson.ObjectId) bson.M {
+	if comment.Hex() != "" {
+		return bson.M{"comment_id": comment}
+	}
+
+	return bson.M{"post_id": post, "comment_id": bson.M{"$exists": false}}
+}
+
+// GetReactionsForPosts returns the reaction of the user to each one of the given posts, posts without
+// a reaction of the user are not included
+func GetReactionsForPosts(posts []bson.ObjectId, user bson.ObjectId, conn interfaces.Conn) map[bson.ObjectId]ReactionType {
+	var r Reaction
+	result := make(map[bson.ObjectId]ReactionType)
+
+	iter := conn.C("likes").Find(bson.M{
+		"post_id":    bson.M{"$in": posts},
+		"user_id":    user,
+		"comment_id": bson.M{"$exists": false},
+	}).Iter()
+	for iter.Next(&r) {
+		result[r.PostID] = r.Kind()
+	}
+
+	iter.Close()
+
+	return result
+}
+
+// SetCommentReactions sets the reaction of the user to each one of the given comments
+func SetCommentReactions(comments []Comment, user bson.ObjectId, conn interfaces.Conn) {
+	var r Reaction
+
+	if len(comments) == 0 {
+		return
+	}
+
+	ids := make([]bson.ObjectId, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+
+	reactions := make(map[bson.ObjectId]ReactionType)
+	iter := conn.C("likes").Find(bson.M{"comment_id": bson.M{"$in": ids}, "user_id": user}).Iter()
+	for iter.Next(&r) {
+		reactions[r.CommentID] = r.Kind()
+	}
+
+	iter.Close()
+
+	for i := range comments {
+		comments[i].Reaction = reactions[comments[i].ID]
+	}
+}
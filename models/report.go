@@ -0,0 +1,88 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// ReportReason is the category of the reason a content was reported for
+type ReportReason int
+
+// ReportStatus is the status of a report in the moderation queue
+type ReportStatus int
+
+const (
+	// Report reasons
+	ReportSpam       = 1
+	ReportHarassment = 2
+	ReportHateSpeech = 3
+	ReportViolence   = 4
+	ReportNudity     = 5
+	ReportOther      = 6
+
+	// Report statuses
+	ReportPending         = 1
+	ReportDismissed       = 2
+	ReportContentRemoved  = 3
+	ReportAuthorSuspended = 4
+
+	// Number of reports after which a content is hidden until it is reviewed
+	ReportsToHide = 5
+)
+
+// Report model, a report of a post or a comment sent by an user
+type Report struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	UserID    bson.ObjectId `json:"user_id" bson:"user_id"`
+	AuthorID  bson.ObjectId `json:"author_id" bson:"author_id"`
+	PostID    bson.ObjectId `json:"post_id" bson:"post_id"`
+	CommentID bson.ObjectId `json:"comment_id,omitempty" bson:"comment_id,omitempty"`
+	Reason    ReportReason  `json:"reason" bson:"reason"`
+	Details   string        `json:"details,omitempty" bson:"details,omitempty"`
+	Status    ReportStatus  `json:"status" bson:"status"`
+	Created   float64       `json:"created" bson:"created"`
+	Resolved  float64       `json:"resolved,omitempty" bson:"resolved,omitempty"`
+
+	// Reported content, only used for display
+	Post    *Post    `json:"post,omitempty" bson:"-"`
+	Comment *Comment `json:"comment,omitempty" bson:"-"`
+}
+
+// NewReport returns a new pending report sent by the given user
+func NewReport(user bson.ObjectId, reason ReportReason) *Report {
+	r := new(Report)
+	r.UserID = user
+	r.Reason = reason
+	r.Status = ReportPending
+	r.Created = float64(time.Now().Unix())
+
+	return r
+}
+
+// IsValidReportReason determines if the given ReportReason is valid or not
+func IsValidReportReason(r ReportReason) bool {
+	return r >= ReportSpam && r <= ReportOther
+}
+
+// Save inserts the Report instance if it hasn't been created yet or updates it if it has
+func (r *Report) Save(conn interfaces.Saver) error {
+	if r.ID.Hex() == "" {
+		r.ID = bson.NewObjectId()
+	}
+
+	if err := conn.Save("reports", r.ID, r); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ContentQuery returns the query matching all the reports of the same content as the report
+func (r *Report) ContentQuery() bson.M {
+	if r.CommentID.Hex() != "" {
+		return bson.M{"comment_id": r.CommentID}
+	}
+
+	return bson.M{"post_id": r.PostID, "comment_id": bson.M{"$exists": false}}
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"github.com/mvader/sunglasses/services/interfaces"
+	"labix.org/v2/mgo/bson"
+)
+
+// NewReshare returns a new post resharing the given one. Reshares always reference the original post,
+// even if what is reshared is another reshare.
+func NewReshare(original *Post, user *User) *Post {
+	p := NewPost(PostReshare, user)
+	p.ReshareOf = original.ID
+	if original.ReshareOf.Hex() != "" {
+		p.ReshareOf = original.ReshareOf
+	}
+
+	return p
+}
+
+// CanBeResharedWith determines if the post can be reshared by the user with the given privacy settings.
+// Public posts can be reshared with anyone, the rest of the posts can only be reshared if everyone in
+// the audience of the reshare can access the post.
+func (p *Post) CanBeResharedWith(privacy PrivacySettings, user *User, conn interfaces.Conn) bool {
+	var audience []bson.ObjectId
+
+	if p.Type == PostReshare || !p.CanBeAccessedBy(user, conn) {
+		return false
+	}
+
+	if p.Privacy.Type == PrivacyPublic && p.albumIsPublic(conn) {
+		return true
+	}
+
+	switch int(privacy.Type) {
+	case PrivacyNone:
+		return true
+	case PrivacyNoneBut:
+		audience = privacy.Users
+		break
+	case PrivacyFollowersOnly, PrivacyFollowersBut:
+		audience = followUsers(bson.M{"user_to": user.ID}, true, privacy.Users, conn)
+		break
+	case PrivacyFollowingOnly, PrivacyFollowingBut:
+		audience = followUsers(bson.M{"user_from": user.ID}, false, privacy.Users, conn)
+		break
+	case PrivacyAllBut:
+		// Everyone excluded from the post must be excluded from the reshare too
+		if p.Privacy.Type != PrivacyAllBut || !p.albumIsPublic(conn) {
+			return false
+		}
+
+		for _, u := range p.Privacy.Users {
+			if !containsID(privacy.Users, u) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
+
+	for _, u := range audience {
+		if !p.CanBeAccessedBy(&User{ID: u}, conn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SetReshareOriginals fills the original posts of the given reshares along with the data of their authors.
+// Originals that have expired or have been hidden are left out, unless the user is the author of a hidden one.
+func SetReshareOriginals(posts []Post, user *User, conn interfaces.Conn) {
+	var (
+		originals []Post
+		ids       = make([]bson.ObjectId, 0, len(posts))
+		users     = make([]bson.ObjectId, 0, len(posts))
+	)
+
+	for _, p := range posts {
+		if p.ReshareOf.Hex() != "" {
+			ids = append(ids, p.ReshareOf)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := conn.C("posts").Find(bson.M{"_id": bson.M{"$in": ids}}).All(&originals); err != nil {
+		return
+	}
+
+	for _, o := range originals {
+		users = append(users, o.UserID)
+	}
+
+	data := GetUsersData(users, user, conn)
+	for i := range originals {
+		originals[i].User = data[originals[i].UserID]
+	}
+
+	for i, p := range posts {
+		for j := range originals {
+			o := &originals[j]
+			if o.Expired() || (o.Hidden && o.UserID.Hex() != user.ID.Hex()) {
+				continue
+			}
+
+			if o.ID.Hex() == p.ReshareOf.Hex() {
+				posts[i].Original = o
+				break
+			}
+		}
+	}
+}
+
+// albumIsPublic returns if the album of the post, if any, can be accessed by everyone
+func (p *Post) albumIsPublic(conn interfaces.Conn) bool {
+	var album PhotoAlbum
+
+	if p.AlbumID.Hex() == "" {
+		return true
+	}
+
+	if err := conn.C("albums").FindId(p.AlbumID).One(&album); err != nil {
+		return true
+	}
+
+	return album.Privacy.Type == PrivacyPublic
+}
+
+// followUsers returns the followers of the user, or the users followed by the user, matching the
+// query except the ones in the excluded list
+func followUsers(query bson.M, followers bool, excluded []bson.ObjectId, conn interfaces.Conn) []bson.ObjectId {
+	var (
+		f     Follow
+		users []bson.ObjectId
+	)
+
+	iter := conn.C("follows").Find(query).Iter()
+	for iter.Next(&f) {
+		u := f.To
+		if followers {
+			u = f.From
+		}
+
+		if !containsID(excluded, u) {
+			users = append(users, u)
+		}
+	}
+
+	iter.Close()
+
+	return users
+}
+
+func containsID(ids []bson.ObjectId, id bson.ObjectId) bool {
+	for _, i := range ids {
+		if i.Hex() == id.Hex() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -7,7 +7,7 @@ type TimelineEntry struct {
 	User     bson.ObjectId   `bson:"user_id"`
 	Post     bson.ObjectId   `bson:"post_id"`
 	PostUser bson.ObjectId   `bson:"post_user_id"`
-	Liked    bool            `bson:"liked"`
+	Reaction ReactionType    `bson:"reaction,omitempty"`
 	Comments []bson.ObjectId `bson:"comments"`
 	Time     float64         `bson:"time"`
 }
@@ -23,8 +23,9 @@ type RecoveryMethod int
 
 const (
 	// Roles
-	RoleUser  = 0
-	RoleAdmin = 1
+	RoleUser      = 0
+	RoleAdmin     = 1
+	RoleModerator = 2
 
 	// Genders
 	Male   = 1
@@ -46,6 +47,9 @@ const (
 
 var (
 	AvailableLanguages = []string{"en", "es"}
+
+	// InfoFields are the names of the UserInfo fields that can have their own privacy settings
+	InfoFields = []string{"work", "education", "hobbies", "books", "movies", "tv", "gender", "websites", "status", "about"}
 )
 
 // User represents an application user
@@ -65,6 +69,8 @@ type User struct {
 	PublicAvatar          string        `json:"public_avatar" bson:"public_avatar,omitempty"`
 	PublicAvatarThumbnail string        `json:"public_avatar_thumbnail" bson:"public_avatar_thumbnail,omitempty"`
 	Active                bool          `json:"active,omitempty" bson:"active"`
+	Deleted               float64       `json:"-" bson:"deleted,omitempty"`
+	Suspended             bool          `json:"-" bson:"suspended,omitempty"`
 	Info                  UserInfo      `json:"info,omitempty" bson:"info"`
 	Settings              UserSettings  `json:"settings,omitempty" bson:"settings"`
 }
@@ -81,6 +87,8 @@ type UserInfo struct {
 	Websites  []string   `json:"websites,omitempty" bson:"websites,omitempty"`
 	Status    UserStatus `json:"status,omitempty" bson:"status,omitempty"`
 	About     string     `json:"about,omitempty" bson:"about,omitempty"`
+	// Privacy settings for every field, the keys are the ones in InfoFields
+	Privacy map[string]PrivacySettings `json:"privacy,omitempty" bson:"privacy,omitempty"`
 }
 
 // UserSettings stores the user preferences
@@ -106,6 +114,13 @@ type UserSettings struct {
 	DefaultPhotoPrivacy    PrivacySettings `json:"default_photo_privacy" bson:"default_photo_privacy"`
 	DefaultLinkPrivacy     PrivacySettings `json:"default_link_privacy" bson:"default_link_privacy"`
 	DefaultAlbumPrivacy    PrivacySettings `json:"default_album_privacy" bson:"default_album_privacy"`
+	// Number of seconds after which new posts expire, 0 if they should not expire by default
+	DefaultPostExpiration int64 `json:"default_post_expiration" bson:"default_post_expiration"`
+	// If this is false posts with a content warning or sensitive media are collapsed
+	ExpandSensitiveContent bool `json:"expand_sensitive_content" bson:"expand_sensitive_content"`
+	// If this is true likes in the comments of the user are not notified even if NotifyLikes is set. It is
+	// stored inverted so the accounts created before it existed keep being notified.
+	MuteCommentLikes bool `json:"mute_comment_likes" bson:"mute_comment_likes,omitempty"`
 }
 
 // NewUser returns a new User instance
@@ -117,6 +132,11 @@ func NewUser() *User {
 	return user
 }
 
+// CanModerate returns if the user can review the reports sent by other users
+func (u *User) CanModerate() bool {
+	return u.Role == RoleAdmin || u.Role == RoleModerator
+}
+
 // Save inserts the User instance if it hasn't been created yet or updates it if it has
 func (u *User) Save(conn interfaces.Conn) error {
 	var count int
@@ -225,22 +245,101 @@ func (us UserSettings) GetPrivacySettings(objectType ObjectType) PrivacySettings
 	}
 }
 
-// UserExists returns the user if exists or nil
+// UserExists returns the user if exists and its account has not been deleted or nil
 func UserExists(conn interfaces.Conn, ID bson.ObjectId) *User {
 	var (
 		err  error
 		user = new(User)
 	)
 
-	if err = conn.C("users").FindId(ID).One(user); err != nil {
+	if err = conn.C("users").Find(bson.M{"_id": ID, "active": true}).One(user); err != nil {
 		return nil
 	}
 
 	return user
 }
 
+// InfoPrivacy returns the privacy settings of the given info field. If the user has not set any
+// privacy for the field it will be visible for followers only if DisplayInfoFollowersOnly is true
+// and for everyone if it is not
+func (u *User) InfoPrivacy(field string) PrivacySettings {
+	if p, ok := u.Info.Privacy[field]; ok && IsValidPrivacyType(p.Type) {
+		return p
+	}
+
+	p := PrivacySettings{Type: PrivacyPublic}
+	if u.Settings.DisplayInfoFollowersOnly {
+		p.Type = PrivacyFollowersOnly
+	}
+
+	return p
+}
+
+// InfoWithPrivacy returns the user info with the privacy settings of all its fields
+func (u *User) InfoWithPrivacy() UserInfo {
+	info := u.Info
+	info.Privacy = make(map[string]PrivacySettings)
+	for _, f := range InfoFields {
+		info.Privacy[f] = u.InfoPrivacy(f)
+	}
+
+	return info
+}
+
+// InfoForUser returns the user info containing only the fields the given user is allowed to see.
+// follows must be true if the given user follows the user and followedBy if the user follows the given user
+func (u *User) InfoForUser(userID bson.ObjectId, follows, followedBy bool) UserInfo {
+	if u.ID.Hex() == userID.Hex() {
+		return u.InfoWithPrivacy()
+	}
+
+	info := u.Info
+	info.Privacy = nil
+
+	for _, f := range InfoFields {
+		if u.InfoPrivacy(f).CanBeAccessedBy(userID, follows, followedBy) {
+			continue
+		}
+
+		switch f {
+		case "work":
+			info.Work = ""
+		case "education":
+			info.Education = ""
+		case "hobbies":
+			info.Hobbies = ""
+		case "books":
+			info.Books = ""
+		case "movies":
+			info.Movies = ""
+		case "tv":
+			info.TV = ""
+		case "gender":
+			info.Gender = 0
+		case "websites":
+			info.Websites = nil
+		case "status":
+			info.Status = 0
+		case "about":
+			info.About = ""
+		}
+	}
+
+	return info
+}
+
 // GetUserData retrieves basic data from users for responses
 func GetUsersData(ids []bson.ObjectId, user *User, conn interfaces.Conn) map[bson.ObjectId]map[string]interface{} {
+	return getUsersData(ids, user, conn, false)
+}
+
+// GetUsersProfileData retrieves the data from users for their profiles, which also includes the info of
+// the users that can be accessed by the user
+func GetUsersProfileData(ids []bson.ObjectId, user *User, conn interfaces.Conn) map[bson.ObjectId]map[string]interface{} {
+	return getUsersData(ids, user, conn, true)
+}
+
+func getUsersData(ids []bson.ObjectId, user *User, conn interfaces.Conn, includeInfo bool) map[bson.ObjectId]map[string]interface{} {
 	var (
 		u        User
 		follows  []Follow
@@ -248,7 +347,7 @@ func GetUsersData(ids []bson.ObjectId, user *User, conn interfaces.Conn) map[bso
 	)
 
 	users := make(map[bson.ObjectId]map[string]interface{})
-	cursor := conn.C("users").Find(bson.M{"_id": bson.M{"$in": ids}}).Iter()
+	cursor := conn.C("users").Find(bson.M{"_id": bson.M{"$in": ids}, "active": true}).Iter()
 
 	followsIter := conn.C("follows").Find(bson.M{"$or": []bson.M{
 		bson.M{"user_from": user.ID, "user_to": bson.M{"$in": ids}},
@@ -276,24 +375,20 @@ func GetUsersData(ids []bson.ObjectId, user *User, conn interfaces.Conn) map[bso
 	}
 
 	for cursor.Next(&u) {
-		hasAccess := false
 		followed := false
+		followsUser := false
 		for _, v := range follows {
-			if v.From.Hex() == u.ID.Hex() || v.To.Hex() == u.ID.Hex() {
-				hasAccess = true
-
-				if v.To.Hex() == u.ID.Hex() {
-					followed = true
-				}
+			if v.To.Hex() == u.ID.Hex() {
+				followed = true
 			}
-		}
 
-		if u.ID.Hex() == user.ID.Hex() {
-			hasAccess = true
+			if v.From.Hex() == u.ID.Hex() {
+				followsUser = true
+			}
 		}
 
 		if _, ok := users[u.ID]; !ok {
-			users[u.ID] = UserForDisplay(u, hasAccess, hasAccess)
+			users[u.ID] = UserForDisplay(u, user.ID, followed, followsUser, includeInfo)
 			users[u.ID]["followed"] = followed
 			users[u.ID]["follow_requested"] = false
 			for _, req := range requests {
@@ -312,9 +407,11 @@ func GetUsersData(ids []bson.ObjectId, user *User, conn interfaces.Conn) map[bso
 	return users
 }
 
-// UserForDisplay returns a displayable version of the user model based on the users permissions
-func UserForDisplay(u User, hasAccess, includeInfo bool) map[string]interface{} {
+// UserForDisplay returns a displayable version of the user model based on the users permissions.
+// followed must be true if the viewer follows the user and followsViewer if the user follows the viewer
+func UserForDisplay(u User, viewer bson.ObjectId, followed, followsViewer, includeInfo bool) map[string]interface{} {
 	var user map[string]interface{}
+	hasAccess := followed || followsViewer || u.ID.Hex() == viewer.Hex()
 
 	if !(u.Settings.Invisible && !hasAccess) {
 		user = map[string]interface{}{
@@ -335,8 +432,8 @@ func UserForDisplay(u User, hasAccess, includeInfo bool) map[string]interface{}
 			user["private_name"] = u.PrivateName
 		}
 
-		if hasAccess && includeInfo {
-			user["info"] = u.Info
+		if includeInfo {
+			user["info"] = u.InfoForUser(viewer, followed, followsViewer)
 		}
 	} else {
 		user = map[string]interface{}{
@@ -3,11 +3,8 @@ package models
 type VideoService int
 
 const (
-	// Service code
-	VideoServiceYoutube = 1
-	VideoServiceVimeo   = 2
-
-	// Services regexps
-	VideoServiceYoutubeRegexp = "youtube.com/watch?(.*)v=(.*)"
-	VideoServiceVimeoRegexp   = "vimeo.com/([0-9]+)"
+	// Service code of the built-in video providers, videos of configured providers have no service code
+	VideoServiceYoutube     = 1
+	VideoServiceVimeo       = 2
+	VideoServiceDailymotion = 3
 )
@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"github.com/mvader/sunglasses/modules/upload"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"os"
+	"time"
+)
+
+// PurgeAccount irreversibly removes a deactivated user account and all its related content such as
+// posts, comments, images, follows, etc. The images that could not be removed are pushed as failed
+// operations of the purge task so they can be cleaned up later.
+func PurgeAccount(c middleware.Context, user *models.User) error {
+	var (
+		posts  []models.Post
+		cmt    models.Comment
+		job    models.Job
+		draft  models.Draft
+		failed bool
+	)
+
+	ID := c.Tasks.PushTask("purge_user", user.ID.Hex())
+
+	removeFile := func(path string) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			c.Tasks.PushFail("purge_user", ID, path)
+			failed = true
+		}
+	}
+
+	// Destroy all user tokens
+	c.RemoveAll("tokens", bson.M{"user_id": user.ID})
+
+	// Destroy all user material (posts + comments + images), including the posts of other users in the
+	// profile of the user. Photos are removed first so the ones that can't be removed are reported.
+	query := bson.M{"$or": []bson.M{bson.M{"user_id": user.ID}, bson.M{"target_id": user.ID}}}
+	if err := c.Find("posts", query).All(&posts); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if posts[i].Type == models.PostPhoto {
+			for _, photo := range posts[i].Images() {
+				removeFile(upload.ToLocalImagePath(photo.URL, c.Config))
+				removeFile(upload.ToLocalThumbnailPath(photo.Thumbnail, c.Config))
+			}
+		}
+
+		// Reshares of previous posts have already been deleted along with them
+		if err := DeletePost(c, &posts[i]); err != nil && err != mgo.ErrNotFound {
+			return err
+		}
+	}
+
+	// Destroy all user comments
+	iter := c.Find("comments", bson.M{"user_id": user.ID}).Iter()
+	for iter.Next(&cmt) {
+		go timeline.PropagatePostOnCommentDeleted(c, cmt.PostID, cmt.ID)
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	// Destroy the data exports of the user
+	iter = c.Find("jobs", bson.M{"user_id": user.ID, "file": bson.M{"$exists": true}}).Iter()
+	for iter.Next(&job) {
+		removeFile(job.File)
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	// Destroy the photos of the user drafts
+	iter = c.Find("drafts", bson.M{"user_id": user.ID}).Iter()
+	for iter.Next(&draft) {
+		for _, photo := range draft.Photos {
+			removeFile(upload.ToLocalImagePath(photo.URL, c.Config))
+			removeFile(upload.ToLocalThumbnailPath(photo.Thumbnail, c.Config))
+		}
+	}
+
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	// Remove other stuff related to the user
+	c.Query("comments").UpdateAll(bson.M{"user_id": user.ID, "replies": bson.M{"$gt": 0}}, bson.M{
+		"$set":   bson.M{"deleted": true, "message": ""},
+		"$unset": bson.M{"hashtags": "", "mentions": "", "hidden": "", "edited": "", "edited_at": ""},
+	})
+	c.RemoveAll("comments", bson.M{"user_id": user.ID, "deleted": bson.M{"$exists": false}})
+	c.RemoveAll("follows", bson.M{"user_to": user.ID})
+	c.RemoveAll("follows", bson.M{"user_from": user.ID})
+	c.RemoveAll("blocks", bson.M{"user_to": user.ID})
+	c.RemoveAll("blocks", bson.M{"user_from": user.ID})
+	c.RemoveAll("requests", bson.M{"user_to": user.ID})
+	c.RemoveAll("requests", bson.M{"user_from": user.ID})
+	c.RemoveAll("timelines", bson.M{"user_id": user.ID})
+	c.RemoveAll("likes", bson.M{"user_id": user.ID})
+	c.RemoveAll("albums", bson.M{"user_id": user.ID})
+	c.RemoveAll("post_revisions", bson.M{"user_id": user.ID})
+	c.RemoveAll("comment_revisions", bson.M{"user_id": user.ID})
+	c.RemoveAll("reports", bson.M{"user_id": user.ID})
+	c.RemoveAll("jobs", bson.M{"user_id": user.ID})
+	c.RemoveAll("drafts", bson.M{"user_id": user.ID})
+	c.RemoveAll("bookmarks", bson.M{"user_id": user.ID})
+	c.RemoveAll("poll_votes", bson.M{"user_id": user.ID})
+	go timeline.PropagatePostOnUserDeleted(c, user.ID)
+
+	// Destroy user
+	if err := c.Remove("users", bson.M{"_id": user.ID}); err != nil {
+		return err
+	}
+
+	// Destroy all user notifications
+	c.RemoveAll("notifications", bson.M{"user_id": user.ID})
+
+	// Remove user avatars
+	if user.Avatar != "" {
+		removeFile(upload.ToLocalImagePath(user.Avatar, c.Config))
+	}
+
+	if user.AvatarThumbnail != "" {
+		removeFile(upload.ToLocalThumbnailPath(user.AvatarThumbnail, c.Config))
+	}
+
+	if user.PublicAvatar != "" {
+		removeFile(upload.ToLocalImagePath(user.PublicAvatar, c.Config))
+	}
+
+	if user.PublicAvatarThumbnail != "" {
+		removeFile(upload.ToLocalThumbnailPath(user.PublicAvatarThumbnail, c.Config))
+	}
+
+	// The task is kept if some files could not be removed
+	if !failed {
+		c.Tasks.TaskDone("purge_user", ID)
+	}
+
+	return nil
+}
+
+// PurgeDeletedAccounts purges all the deactivated accounts whose grace period has already passed
+func PurgeDeletedAccounts(c middleware.Context) error {
+	var users []models.User
+
+	limit := float64(time.Now().Add(-c.Config.AccountDeletionGracePeriod()).Unix())
+	if err := c.Find("users", bson.M{"active": false, "deleted": bson.M{"$gt": 0, "$lte": limit}}).All(&users); err != nil {
+		return err
+	}
+
+	for i := range users {
+		if err := PurgeAccount(c, &users[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
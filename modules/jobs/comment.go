@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// RemoveComment removes a comment from the database along with its likes, notifications and revisions.
+// Comments with replies are kept as a placeholder without message so their replies can still be
+// displayed, and placeholders are removed once they have no replies left. Returns if the comment was
+// kept as a placeholder.
+func RemoveComment(c middleware.Context, comment *models.Comment) (bool, error) {
+	c.RemoveAll("likes", bson.M{"comment_id": comment.ID})
+	c.RemoveAll("notifications", bson.M{"comment_id": comment.ID})
+	c.RemoveAll("comment_revisions", bson.M{"comment_id": comment.ID})
+
+	if comment.Replies > 0 {
+		err := c.Query("comments").UpdateId(comment.ID, bson.M{
+			"$set":   bson.M{"deleted": true, "message": ""},
+			"$unset": bson.M{"hashtags": "", "mentions": "", "hidden": "", "edited": "", "edited_at": ""},
+		})
+
+		return true, err
+	}
+
+	if err := c.Query("comments").RemoveId(comment.ID); err != nil {
+		return false, err
+	}
+
+	if comment.ParentID.Hex() != "" {
+		var parent models.Comment
+
+		c.Query("comments").UpdateId(comment.ParentID, bson.M{"$inc": bson.M{"replies": -1}})
+		if err := c.FindId("comments", comment.ParentID).One(&parent); err == nil && parent.Deleted && parent.Replies <= 0 {
+			RemoveComment(c, &parent)
+		}
+	}
+
+	return false, nil
+}
+
+// NotifyReply notifies the author of a comment that it has been replied by the user. There is only one
+// notification for each comment with the last user that replied it.
+func NotifyReply(c middleware.Context, parent *models.Comment) {
+	var (
+		n    models.Notification
+		user models.User
+	)
+
+	if parent.UserID.Hex() == c.User.ID.Hex() || parent.Deleted {
+		return
+	}
+
+	query := bson.M{
+		"notification_type": models.NotificationCommentReplied,
+		"user_id":           parent.UserID,
+		"comment_id":        parent.ID,
+	}
+
+	if err := c.Find("notifications", query).One(&n); err == nil {
+		n.UserActionID = c.User.ID
+		n.Time = float64(time.Now().Unix())
+		n.Read = false
+		(&n).Save(c.Conn)
+		return
+	}
+
+	if err := c.FindId("users", parent.UserID).One(&user); err != nil || !user.Settings.NotifyNewCommentOthers ||
+		models.UserIsBlocked(user.ID, c.User.ID, c.Conn) {
+		return
+	}
+
+	n.Type = models.NotificationCommentReplied
+	n.User = user.ID
+	n.PostID = parent.PostID
+	n.CommentID = parent.ID
+	n.UserActionID = c.User.ID
+	n.Time = float64(time.Now().Unix())
+	(&n).Save(c.Conn)
+}
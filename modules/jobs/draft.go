@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/upload"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// DeleteDraft deletes a draft along with its photo files
+func DeleteDraft(c middleware.Context, draft *models.Draft) error {
+	if err := c.Query("drafts").RemoveId(draft.ID); err != nil {
+		return err
+	}
+
+	for _, photo := range draft.Photos {
+		upload.RemoveImage(photo.URL, photo.Thumbnail, c.Config)
+	}
+
+	return nil
+}
+
+// DeleteAbandonedDrafts deletes all the drafts that have not been updated during the configured draft TTL
+func DeleteAbandonedDrafts(c middleware.Context) error {
+	var drafts []models.Draft
+
+	limit := float64(time.Now().Add(-c.Config.DraftTTL()).Unix())
+	if err := c.Find("drafts", bson.M{"updated": bson.M{"$lte": limit}}).All(&drafts); err != nil {
+		return err
+	}
+
+	for i := range drafts {
+		if err := DeleteDraft(c, &drafts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
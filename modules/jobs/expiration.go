@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/preview"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"github.com/mvader/sunglasses/modules/upload"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// DeletePost deletes a post along with its photo files, link preview image if no longer used, comments, likes,
+// notifications, revisions, bookmarks, poll votes and timeline entries
+func DeletePost(c middleware.Context, post *models.Post) error {
+	if err := c.Query("posts").RemoveId(post.ID); err != nil {
+		return err
+	}
+
+	if post.Type == models.PostPhoto {
+		for _, photo := range post.Images() {
+			upload.RemoveImage(photo.URL, photo.Thumbnail, c.Config)
+		}
+	}
+
+	if post.Preview != nil {
+		preview.RemoveImage(c.Conn, c.Config, post.Preview.Image, post.Preview.ImageThumbnail)
+	}
+
+	c.RemoveAll("comments", bson.M{"post_id": post.ID})
+	c.RemoveAll("likes", bson.M{"post_id": post.ID})
+	c.RemoveAll("notifications", bson.M{"post_id": post.ID})
+	c.RemoveAll("post_revisions", bson.M{"post_id": post.ID})
+	c.RemoveAll("comment_revisions", bson.M{"post_id": post.ID})
+	c.RemoveAll("bookmarks", bson.M{"post_id": post.ID})
+	c.RemoveAll("poll_votes", bson.M{"post_id": post.ID})
+
+	go timeline.PropagatePostsOnDeletion(c, post.ID)
+
+	// Scheduled reshares are only counted in the original once they are published
+	if post.ReshareOf.Hex() != "" && post.Scheduled == 0 {
+		c.Query("posts").UpdateId(post.ReshareOf, bson.M{"$inc": bson.M{"reshares": -1}})
+	}
+
+	// Reshares disappear along with the original post
+	var reshares []models.Post
+	if err := c.Find("posts", bson.M{"reshare_of": post.ID}).All(&reshares); err != nil {
+		return err
+	}
+
+	for i := range reshares {
+		if err := DeletePost(c, &reshares[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpiredPosts deletes all the posts whose expiration time has already passed
+func DeleteExpiredPosts(c middleware.Context) error {
+	var posts []models.Post
+
+	if err := c.Find("posts", bson.M{"expires": bson.M{"$gt": 0, "$lte": float64(time.Now().Unix())}}).All(&posts); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		if err := DeletePost(c, &posts[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RunExpirationSweeper deletes the expired posts, data exports, link previews and abandoned drafts and purges
+// the deleted accounts whose grace period has passed every time the given interval passes.
+// It never returns so it must be run in its own goroutine
+func RunExpirationSweeper(c middleware.Context, interval time.Duration) {
+	for _ = range time.Tick(interval) {
+		DeleteExpiredPosts(c)
+		DeleteExpiredExports(c)
+		preview.DeleteExpired(c.Conn, c.Config)
+		DeleteAbandonedDrafts(c)
+		PurgeDeletedAccounts(c)
+	}
+}
@@ -0,0 +1,207 @@
+package jobs
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/upload"
+	"github.com/mvader/sunglasses/services"
+	"github.com/mvader/sunglasses/util"
+	"io"
+	"labix.org/v2/mgo/bson"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// exportProfile is the profile of the user in a data export, it includes the fields that are never sent
+// to the clients but belong to the user
+type exportProfile struct {
+	*models.User
+	EMail string `json:"email,omitempty"`
+}
+
+// ExportUserData generates a zip file with all the data of the user: profile, settings, posts, comments, likes,
+// follows, blocks, notifications and the uploaded photos. The file will be available for download until
+// the job expires and the user is notified when it's ready. The job fails if there is no exports path
+// configured instead of writing the file in the working directory.
+func ExportUserData(c middleware.Context, job *models.Job, user *models.User) {
+	ID := c.Tasks.PushTask("export_data", job.ID.Hex())
+
+	c.AsyncQuery(func(conn *services.Connection) {
+		var (
+			photos []string
+			p      models.Post
+		)
+
+		fail := func() {
+			if err := job.Finish(true, conn); err == nil {
+				c.Tasks.TaskDone("export_data", ID)
+			}
+		}
+
+		if c.Config.ExportsPath == "" {
+			fail()
+			return
+		}
+
+		collections := []struct {
+			name  string
+			query bson.M
+		}{
+			{"posts", bson.M{"user_id": user.ID}},
+			{"albums", bson.M{"user_id": user.ID}},
+			{"post_revisions", bson.M{"user_id": user.ID}},
+			{"comment_revisions", bson.M{"user_id": user.ID}},
+			{"reports", bson.M{"user_id": user.ID}},
+			{"comments", bson.M{"user_id": user.ID}},
+			{"likes", bson.M{"user_id": user.ID}},
+			{"bookmarks", bson.M{"user_id": user.ID}},
+			{"poll_votes", bson.M{"user_id": user.ID}},
+			{"follows", bson.M{"$or": []bson.M{bson.M{"user_from": user.ID}, bson.M{"user_to": user.ID}}}},
+			{"blocks", bson.M{"user_from": user.ID}},
+			{"notifications", bson.M{"user_id": user.ID}},
+		}
+
+		for _, v := range []string{user.Avatar, user.PublicAvatar} {
+			if v != "" {
+				photos = append(photos, upload.ToLocalImagePath(v, c.Config))
+			}
+		}
+
+		iter := conn.Db.C("posts").Find(bson.M{"user_id": user.ID, "post_type": models.PostPhoto}).Iter()
+		for iter.Next(&p) {
+			for _, photo := range p.Images() {
+				photos = append(photos, upload.ToLocalImagePath(photo.URL, c.Config))
+			}
+		}
+
+		if err := iter.Close(); err != nil {
+			fail()
+			return
+		}
+
+		// profile.json and settings.json + collections + photos
+		job.Total = 2 + len(collections) + len(photos)
+		job.Save(conn)
+
+		job.File = c.Config.ExportsPath + util.NewFileName("zip")
+		file, err := os.Create(job.File)
+		if err != nil {
+			job.File = ""
+			fail()
+			return
+		}
+
+		w := zip.NewWriter(file)
+		failed := false
+
+		addFile := func(name string, data interface{}) {
+			if err := writeJSON(w, name, data); err != nil {
+				job.Failed++
+			}
+			job.Processed++
+		}
+
+		addFile("profile.json", exportProfile{user, user.EMail})
+		addFile("settings.json", user.Settings)
+
+		for _, col := range collections {
+			var result []bson.M
+			if err := conn.Db.C(col.name).Find(col.query).All(&result); err != nil {
+				job.Failed++
+				job.Processed++
+				continue
+			}
+
+			addFile(col.name+".json", result)
+		}
+
+		job.Save(conn)
+
+		for _, photo := range photos {
+			if err := writeFile(w, "photos/"+filepath.Base(photo), photo); err != nil {
+				job.Failed++
+			}
+
+			job.Processed++
+			if job.Processed%progressInterval == 0 {
+				job.Save(conn)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			failed = true
+		}
+
+		if err := file.Close(); err != nil {
+			failed = true
+		}
+
+		if failed {
+			os.Remove(job.File)
+			job.File = ""
+		} else {
+			job.Expires = float64(time.Now().Add(models.ExportExpirationHours * time.Hour).Unix())
+		}
+
+		if err := job.Finish(failed, conn); err == nil {
+			c.Tasks.TaskDone("export_data", ID)
+		}
+	})
+}
+
+// DeleteExpiredExports removes the files of all the data exports that have expired
+func DeleteExpiredExports(c middleware.Context) error {
+	var job models.Job
+
+	iter := c.Find("jobs", bson.M{
+		"job_type": models.JobDataExport,
+		"file":     bson.M{"$exists": true},
+		"expires":  bson.M{"$lte": float64(time.Now().Unix())},
+	}).Iter()
+
+	for iter.Next(&job) {
+		os.Remove(job.File)
+		job.File = ""
+
+		if err := (&job).Save(c.Conn); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+func writeJSON(w *zip.Writer, name string, data interface{}) error {
+	f, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(bytes)
+	return err
+}
+
+func writeFile(w *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	f, err := w.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+	return err
+}
@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"github.com/mvader/sunglasses/services"
+	"labix.org/v2/mgo/bson"
+)
+
+// progressInterval is the number of processed items after which the job progress is saved
+const progressInterval = 20
+
+// ChangePostsPrivacy applies the given privacy settings to all the posts matching the query and updates the
+// timelines of the user's followers accordingly. The job progress is saved while the posts are processed
+// and the user is notified when the job is finished.
+func ChangePostsPrivacy(c middleware.Context, job *models.Job, query bson.M, privacy models.PrivacySettings) {
+	ID := c.Tasks.PushTask("change_posts_privacy", job.ID.Hex())
+
+	c.AsyncQuery(func(conn *services.Connection) {
+		var posts []models.Post
+
+		// Only the ids are retrieved beforehand because the posts may be moved
+		// while they are updated and thus be returned twice by the iterator
+		if err := conn.Db.C("posts").Find(query).Select(bson.M{"_id": 1}).All(&posts); err != nil {
+			if err := job.Finish(true, conn); err == nil {
+				c.Tasks.TaskDone("change_posts_privacy", ID)
+			}
+			return
+		}
+
+		job.Total = len(posts)
+		job.Save(conn)
+
+		for _, v := range posts {
+			var p models.Post
+			if err := conn.Db.C("posts").FindId(v.ID).One(&p); err != nil || !canChangePrivacy(conn, &p, privacy) {
+				job.Failed++
+			} else {
+				p.Privacy = privacy
+				if err := (&p).Save(conn); err != nil {
+					job.Failed++
+				} else {
+					RemoveInvalidReshares(c, p.ID)
+					UnpinHiddenPosts(c, p.ID)
+					if !c.Config.Debug {
+						if err := timeline.UpdatePostTimelines(conn, &p); err != nil {
+							job.Failed++
+						}
+					}
+				}
+			}
+
+			job.Processed++
+			if job.Processed%progressInterval == 0 {
+				job.Save(conn)
+			}
+		}
+
+		if err := job.Finish(false, conn); err == nil {
+			c.Tasks.TaskDone("change_posts_privacy", ID)
+		}
+	})
+}
+
+// canChangePrivacy returns if the post can have the given privacy settings. Reshares can't be shared
+// with users that can't access the original post.
+func canChangePrivacy(conn *services.Connection, post *models.Post, privacy models.PrivacySettings) bool {
+	var original models.Post
+
+	if post.ReshareOf.Hex() == "" {
+		return true
+	}
+
+	if err := conn.Db.C("posts").FindId(post.ReshareOf).One(&original); err != nil {
+		return false
+	}
+
+	return (&original).CanBeResharedWith(privacy, &models.User{ID: post.UserID}, conn)
+}
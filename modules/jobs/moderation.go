@@ -0,0 +1,80 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// DeleteComment deletes a comment and removes it from the timelines
+func DeleteComment(c middleware.Context, comment *models.Comment) error {
+	var post models.Post
+
+	if _, err := RemoveComment(c, comment); err != nil {
+		return err
+	}
+
+	if err := c.FindId("posts", comment.PostID).One(&post); err == nil {
+		post.CommentsNum--
+		(&post).Save(c.Conn)
+
+		go timeline.PropagatePostOnCommentDeleted(c, post.ID, comment.ID)
+	}
+
+	return nil
+}
+
+// SuspendUser suspends the account of the user. The account is hidden and all its tokens are revoked.
+func SuspendUser(c middleware.Context, userID bson.ObjectId) error {
+	var user models.User
+
+	if err := c.FindId("users", userID).One(&user); err != nil {
+		return err
+	}
+
+	user.Active = false
+	user.Suspended = true
+	if err := (&user).Save(c.Conn); err != nil {
+		return err
+	}
+
+	c.RemoveAll("tokens", bson.M{"user_id": user.ID})
+
+	return nil
+}
+
+// ResolveReports marks as resolved with the given status all the pending reports of the same content as
+// the given report and notifies the users that sent them of the outcome
+func ResolveReports(c middleware.Context, report *models.Report, status models.ReportStatus) error {
+	var reports []models.Report
+
+	query := report.ContentQuery()
+	query["status"] = models.ReportPending
+	if err := c.Find("reports", query).All(&reports); err != nil {
+		return err
+	}
+
+	now := float64(time.Now().Unix())
+	for i := range reports {
+		reports[i].Status = status
+		reports[i].Resolved = now
+
+		if err := (&reports[i]).Save(c.Conn); err != nil {
+			return err
+		}
+
+		n := models.Notification{}
+		n.Type = models.NotificationReportResolved
+		n.User = reports[i].UserID
+		n.ReportID = reports[i].ID
+		n.Time = now
+
+		if err := n.Save(c.Conn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+)
+
+// UnpinHiddenPosts unpins the given posts if they can't be pinned anymore because they have been hidden from
+// everyone but their author. It must be called every time the audience of a post may have been narrowed.
+func UnpinHiddenPosts(c middleware.Context, posts ...bson.ObjectId) error {
+	var pinned []models.Post
+
+	if err := c.Find("posts", bson.M{"_id": bson.M{"$in": posts}, "pinned": bson.M{"$gt": 0}}).All(&pinned); err != nil {
+		return err
+	}
+
+	for i := range pinned {
+		if !(&pinned[i]).CanBePinned(c.Conn) {
+			if err := c.Query("posts").UpdateId(pinned[i].ID, bson.M{"$unset": bson.M{"pinned": ""}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
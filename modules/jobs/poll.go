@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// ClosePolls marks as closed all the published polls whose closing time has already passed and notifies
+// their authors
+func ClosePolls(c middleware.Context) error {
+	var polls []models.Post
+
+	if err := c.Find("posts", bson.M{
+		"post_type":   models.PostPoll,
+		"poll.closes": bson.M{"$lte": float64(time.Now().Unix())},
+		"poll.closed": false,
+		"scheduled":   bson.M{"$exists": false},
+	}).All(&polls); err != nil {
+		return err
+	}
+
+	for _, p := range polls {
+		var author models.User
+
+		// The author is only notified if the poll has not been closed by someone else meanwhile
+		if err := c.Query("posts").Update(bson.M{"_id": p.ID, "poll.closed": false}, bson.M{
+			"$set": bson.M{"poll.closed": true},
+		}); err != nil {
+			continue
+		}
+
+		if err := c.FindId("users", p.UserID).One(&author); err != nil {
+			continue
+		}
+
+		models.SendNotification(models.NotificationPollClosed, &author, p.ID, "", c.Conn)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/timeline"
+	"labix.org/v2/mgo/bson"
+	"time"
+)
+
+// PublishPost propagates a new post to the timelines and sends the notifications of its creation to the user
+// whose profile it was posted in, the mentioned users and the author of the reshared post, if any.
+// The user of the context must be the author of the post.
+func PublishPost(c middleware.Context, post *models.Post, target *models.User) {
+	go timeline.PropagatePostOnCreation(c, post)
+
+	if target != nil {
+		models.SendNotification(models.NotificationPostOnMyWall, target, post.ID, c.User.ID, c.Conn)
+	}
+
+	NotifyMentions(c, post, nil, nil)
+
+	if post.ReshareOf.Hex() != "" {
+		var original models.Post
+		if err := c.FindId("posts", post.ReshareOf).One(&original); err != nil {
+			return
+		}
+
+		c.Query("posts").UpdateId(original.ID, bson.M{"$inc": bson.M{"reshares": 1}})
+
+		var user models.User
+		if original.UserID.Hex() != c.User.ID.Hex() {
+			if err := c.FindId("users", original.UserID).One(&user); err == nil {
+				models.SendNotification(models.NotificationPostReshared, &user, original.ID, c.User.ID, c.Conn)
+			}
+		}
+	}
+}
+
+// NotifyMentions notifies the users mentioned in the post, or in the comment if one is given, that were
+// not already mentioned before. Users that can't access the post or that have blocked or have been blocked
+// by the author are not notified.
+func NotifyMentions(c middleware.Context, post *models.Post, comment *models.Comment, previous []models.Mention) {
+	var commentID bson.ObjectId
+
+	mentions := post.Mentions
+	if comment != nil {
+		mentions = comment.Mentions
+		commentID = comment.ID
+	}
+
+	for _, m := range mentions {
+		var user models.User
+
+		if m.UserID.Hex() == c.User.ID.Hex() || models.IsMentioned(previous, m.UserID) ||
+			models.UserIsBlocked(m.UserID, c.User.ID, c.Conn) ||
+			models.UserIsBlocked(c.User.ID, m.UserID, c.Conn) {
+			continue
+		}
+
+		if err := c.FindId("users", m.UserID).One(&user); err != nil || !post.CanBeAccessedBy(&user, c.Conn) {
+			continue
+		}
+
+		n := models.Notification{}
+		n.Type = models.NotificationMentioned
+		n.User = user.ID
+		n.PostID = post.ID
+		n.CommentID = commentID
+		n.UserActionID = c.User.ID
+		n.Time = float64(time.Now().Unix())
+		n.Save(c.Conn)
+	}
+}
+
+// PublishScheduledPosts publishes all the scheduled posts whose publish time has already passed
+func PublishScheduledPosts(c middleware.Context) error {
+	var posts []models.Post
+
+	if err := c.Find("posts", bson.M{"scheduled": bson.M{"$gt": 0, "$lte": float64(time.Now().Unix())}}).All(&posts); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		var author models.User
+		post := &posts[i]
+
+		if err := c.FindId("users", post.UserID).One(&author); err != nil {
+			continue
+		}
+
+		// The post is only published if it has not been rescheduled or published by someone else meanwhile
+		err := c.Query("posts").Update(bson.M{"_id": post.ID, "scheduled": post.Scheduled}, bson.M{
+			"$set":   bson.M{"created": post.Scheduled},
+			"$unset": bson.M{"scheduled": "", "expires_relative": ""},
+		})
+		if err != nil {
+			continue
+		}
+
+		post.Created = post.Scheduled
+		post.Scheduled = 0
+
+		var target *models.User
+		if post.TargetID.Hex() != "" {
+			target = models.UserExists(c.Conn, post.TargetID)
+		}
+
+		ctx := c
+		ctx.User = &author
+		PublishPost(ctx, post, target)
+	}
+
+	return nil
+}
+
+// RunPostScheduler publishes the scheduled posts that are due and closes the polls that have finished every
+// time the given interval passes. It never returns so it must be run in its own goroutine
+func RunPostScheduler(c middleware.Context, interval time.Duration) {
+	for _ = range time.Tick(interval) {
+		PublishScheduledPosts(c)
+		ClosePolls(c)
+	}
+}
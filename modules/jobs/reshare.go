@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"github.com/mvader/sunglasses/middleware"
+	"github.com/mvader/sunglasses/models"
+	"labix.org/v2/mgo/bson"
+)
+
+// RemoveInvalidReshares deletes the reshares of the given posts whose audience can't access the post anymore.
+// It must be called every time the audience of a post may have been narrowed.
+func RemoveInvalidReshares(c middleware.Context, posts ...bson.ObjectId) error {
+	var original models.Post
+
+	for _, ID := range posts {
+		var reshares []models.Post
+
+		if err := c.Find("posts", bson.M{"reshare_of": ID}).All(&reshares); err != nil {
+			return err
+		}
+
+		if len(reshares) == 0 {
+			continue
+		}
+
+		if err := c.FindId("posts", ID).One(&original); err != nil {
+			return err
+		}
+
+		for i := range reshares {
+			user := &models.User{ID: reshares[i].UserID}
+			if !(&original).CanBeResharedWith(reshares[i].Privacy, user, c.Conn) {
+				if err := DeletePost(c, &reshares[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package preview
+
+import (
+	"code.google.com/p/go.net/html"
+	"code.google.com/p/go.net/html/atom"
+	"errors"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/upload"
+	"github.com/mvader/sunglasses/services"
+	"github.com/mvader/sunglasses/services/interfaces"
+	"io"
+	"io/ioutil"
+	"labix.org/v2/mgo/bson"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxPageSize is the maximum number of bytes of a page that are read to extract its preview
+	MaxPageSize = 512 << 10
+	// MaxImageSize is the maximum size in bytes of the preview image of a page
+	MaxImageSize = 5 << 20
+
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxSiteNameLength    = 100
+)
+
+// Get returns the preview of the given URL. Previews are only fetched the first time an URL is
+// posted, the cached one is returned afterwards until it expires.
+func Get(conn *services.Connection, config *services.Config, fetcher interfaces.Fetcher, URL string) (*models.LinkPreview, error) {
+	var cached models.LinkPreview
+
+	if err := conn.C("link_previews").Find(bson.M{"url": URL}).One(&cached); err == nil {
+		if cached.Fetched > expirationLimit(config) {
+			return &cached, nil
+		}
+
+		if err := Evict(conn, config, &cached); err != nil {
+			return nil, err
+		}
+	}
+
+	preview, err := Fetch(fetcher, URL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := preview.Save(conn); err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+// Evict removes a cached preview along with its image, unless the image is still used by a post
+func Evict(conn *services.Connection, config *services.Config, p *models.LinkPreview) error {
+	if err := conn.C("link_previews").RemoveId(p.ID); err != nil {
+		return err
+	}
+
+	RemoveImage(conn, config, p.Image, p.ImageThumbnail)
+	return nil
+}
+
+// DeleteExpired evicts all the cached previews that have expired
+func DeleteExpired(conn *services.Connection, config *services.Config) error {
+	var previews []models.LinkPreview
+
+	if err := conn.C("link_previews").Find(bson.M{"fetched": bson.M{"$lte": expirationLimit(config)}}).All(&previews); err != nil {
+		return err
+	}
+
+	for i := range previews {
+		if err := Evict(conn, config, &previews[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveImage removes the files of a preview image if neither a post nor a cached preview uses it.
+// Posts keep a copy of the preview so their image outlives the cached preview it comes from.
+func RemoveImage(conn *services.Connection, config *services.Config, image, thumbnail string) {
+	if image == "" {
+		return
+	}
+
+	if count, err := conn.C("posts").Find(bson.M{"preview.image": image}).Count(); err != nil || count > 0 {
+		return
+	}
+
+	if count, err := conn.C("link_previews").Find(bson.M{"image": image}).Count(); err != nil || count > 0 {
+		return
+	}
+
+	upload.RemoveImage(image, thumbnail, config)
+}
+
+// Fetch retrieves the page at the given URL and extracts its preview. The preview image, if any, is
+// downloaded and stored along with its thumbnail. A page whose image can't be stored still has a preview.
+func Fetch(fetcher interfaces.Fetcher, URL string, config *services.Config) (*models.LinkPreview, error) {
+	resp, err := fetcher.Get(URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, errors.New("invalid link")
+	}
+
+	preview := Parse(io.LimitReader(resp.Body, MaxPageSize), resp.Request.URL)
+	preview.URL = URL
+	preview.Fetched = float64(time.Now().Unix())
+
+	if preview.Image != "" {
+		preview.Image, preview.ImageThumbnail, err = storeImage(fetcher, preview.Image, config)
+		if err != nil {
+			preview.Image = ""
+			preview.ImageThumbnail = ""
+		}
+	}
+
+	return preview, nil
+}
+
+// Parse extracts the preview of a page from its OpenGraph and Twitter card tags, falling back to
+// the title and description of the document. The image is the absolute URL of the remote image.
+func Parse(r io.Reader, pageURL *url.URL) *models.LinkPreview {
+	var (
+		meta    = make(map[string]string)
+		title   string
+		inTitle bool
+	)
+
+	z := html.NewTokenizer(r)
+
+tokens:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// End of the document or of the part of it that was read
+			break tokens
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			switch t.DataAtom {
+			case atom.Meta:
+				key := strings.ToLower(attr(t, "property"))
+				if key == "" {
+					key = strings.ToLower(attr(t, "name"))
+				}
+
+				if _, ok := meta[key]; key != "" && !ok {
+					meta[key] = strings.TrimSpace(attr(t, "content"))
+				}
+				break
+			case atom.Link:
+				for _, rel := range strings.Fields(strings.ToLower(attr(t, "rel"))) {
+					if _, ok := meta["canonical"]; rel == "canonical" && !ok {
+						meta["canonical"] = strings.TrimSpace(attr(t, "href"))
+					}
+				}
+				break
+			case atom.Title:
+				inTitle = true
+				break
+			case atom.Body:
+				// All metadata is in the head of the document
+				break tokens
+			}
+			break
+
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = strings.TrimSpace(z.Token().Data)
+			}
+			break
+
+		case html.EndTagToken:
+			t := z.Token()
+			if t.DataAtom == atom.Head {
+				break tokens
+			}
+
+			if t.DataAtom == atom.Title {
+				inTitle = false
+			}
+		}
+	}
+
+	p := new(models.LinkPreview)
+	p.Title = truncate(first(meta["og:title"], meta["twitter:title"], title, "Untitled"), maxTitleLength)
+	p.Description = truncate(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength)
+	p.SiteName = truncate(meta["og:site_name"], maxSiteNameLength)
+	p.CanonicalURL = first(
+		resolveURL(pageURL, meta["og:url"]),
+		resolveURL(pageURL, meta["canonical"]),
+		pageURL.String(),
+	)
+	p.Image = first(
+		resolveURL(pageURL, meta["og:image:secure_url"]),
+		resolveURL(pageURL, meta["og:image"]),
+		resolveURL(pageURL, meta["og:image:url"]),
+		resolveURL(pageURL, meta["twitter:image"]),
+		resolveURL(pageURL, meta["twitter:image:src"]),
+	)
+
+	return p
+}
+
+// storeImage downloads the image at the given URL and stores it with its thumbnail
+func storeImage(fetcher interfaces.Fetcher, imageURL string, config *services.Config) (string, string, error) {
+	resp, err := fetcher.Get(imageURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", "", errors.New("invalid image")
+	}
+
+	if resp.ContentLength > MaxImageSize {
+		return "", "", errors.New("file too large")
+	}
+
+	return upload.StoreImage(ioutil.NopCloser(io.LimitReader(resp.Body, MaxImageSize)), upload.DefaultUploadOptions(config))
+}
+
+// expirationLimit returns the time before which cached previews have expired
+func expirationLimit(config *services.Config) float64 {
+	return float64(time.Now().Add(-config.LinkPreviewTTL()).Unix())
+}
+
+// attr returns the value of the attribute of the token with the given name
+func attr(t html.Token, name string) string {
+	for _, a := range t.Attr {
+		if strings.ToLower(a.Key) == name {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// resolveURL returns the absolute URL of the reference relative to the page, empty if it's not an http URL
+func resolveURL(pageURL *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	u, err := pageURL.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.String()
+}
+
+// first returns the first of the values that is not empty
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// truncate cuts the string to the given number of characters
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+
+	return s
+}
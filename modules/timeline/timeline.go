@@ -13,7 +13,6 @@ func PropagatePostOnCreation(c middleware.Context, post *models.Post) {
 		t := models.TimelineEntry{
 			Post:     post.ID,
 			PostUser: post.UserID,
-			Liked:    false,
 			Time:     post.Created,
 		}
 
@@ -32,8 +31,26 @@ func PropagatePostOnCreation(c middleware.Context, post *models.Post) {
 				c.Tasks.PushFail("create_post", ID, c.User.ID)
 			}
 
-			iter := conn.Db.C("follows").Find(bson.M{"user_to": post.UserID}).Iter()
+			// Propagate the post on the timeline of the user whose profile it was posted in
+			seen := map[bson.ObjectId]bool{c.User.ID: true}
+			if post.TargetID.Hex() != "" {
+				seen[post.TargetID] = true
+				t.ID = bson.NewObjectId()
+				t.User = post.TargetID
+
+				if _, err := conn.Db.C("timelines").UpsertId(t.ID, t); err != nil {
+					allCompleted = false
+					c.Tasks.PushFail("create_post", ID, post.TargetID)
+				}
+			}
+
+			iter := conn.Db.C("follows").Find(bson.M{"user_to": bson.M{"$in": postOwners(post)}}).Iter()
 			for iter.Next(&f) && allCompleted {
+				if seen[f.From] {
+					continue
+				}
+				seen[f.From] = true
+
 				var u models.User
 				if err := conn.Db.C("users").FindId(f.From).One(&u); err == nil {
 					if post.CanBeAccessedBy(&u, conn) {
@@ -66,7 +83,6 @@ func PropagateSinglePostOnCreation(conn *services.Connection, ts *services.TaskS
 		ID:       bson.NewObjectId(),
 		Post:     p.ID,
 		PostUser: p.UserID,
-		Liked:    false,
 		Time:     p.Created,
 		User:     u,
 	}
@@ -91,6 +107,81 @@ func PropagatePostOnPrivacyChange(c middleware.Context, post *models.Post) {
 	}
 }
 
+// UpdatePostTimelines adds the post to the timelines of the followers that can access it and removes it from
+// the timelines of the ones that can't anymore. Unlike PropagatePostOnPrivacyChange the entries that are kept
+// preserve their likes and comments.
+func UpdatePostTimelines(conn *services.Connection, post *models.Post) error {
+	var f models.Follow
+	seen := make(map[bson.ObjectId]bool)
+
+	iter := conn.Db.C("follows").Find(bson.M{"user_to": bson.M{"$in": postOwners(post)}}).Iter()
+	for iter.Next(&f) {
+		if seen[f.From] {
+			continue
+		}
+		seen[f.From] = true
+
+		var u models.User
+		if err := conn.Db.C("users").FindId(f.From).One(&u); err != nil {
+			continue
+		}
+
+		if post.CanBeAccessedBy(&u, conn) {
+			count, err := conn.Db.C("timelines").Find(bson.M{"user_id": u.ID, "post_id": post.ID}).Count()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+
+			if count == 0 {
+				t := models.TimelineEntry{
+					ID:       bson.NewObjectId(),
+					User:     u.ID,
+					Post:     post.ID,
+					PostUser: post.UserID,
+					Time:     post.Created,
+				}
+
+				if _, err := conn.Db.C("timelines").UpsertId(t.ID, t); err != nil {
+					iter.Close()
+					return err
+				}
+			}
+		} else {
+			if _, err := conn.Db.C("timelines").RemoveAll(bson.M{"user_id": u.ID, "post_id": post.ID}); err != nil {
+				iter.Close()
+				return err
+			}
+		}
+	}
+
+	return iter.Close()
+}
+
+// PropagatePostsOnAlbumChange updates the timelines of the followers for the given photos when they are added to
+// or removed from an album or the privacy of their album changes
+func PropagatePostsOnAlbumChange(c middleware.Context, albumID bson.ObjectId, posts []bson.ObjectId) {
+	if !c.Config.Debug {
+		ID := c.Tasks.PushTask("album_change", albumID.Hex())
+
+		c.AsyncQuery(func(conn *services.Connection) {
+			var p models.Post
+			allCompleted := true
+
+			iter := conn.Db.C("posts").Find(bson.M{"_id": bson.M{"$in": posts}}).Iter()
+			for iter.Next(&p) {
+				if err := UpdatePostTimelines(conn, &p); err != nil {
+					allCompleted = false
+				}
+			}
+
+			if err := iter.Close(); err == nil && allCompleted {
+				c.Tasks.TaskDone("album_change", ID)
+			}
+		})
+	}
+}
+
 // PropagatePostsOnUserFollow propagates the posts to the timeline when a new user is followed
 func PropagatePostsOnUserFollow(c middleware.Context, userID bson.ObjectId) {
 	if !c.Config.Debug {
@@ -107,7 +198,6 @@ func PropagatePostsOnUserFollow(c middleware.Context, userID bson.ObjectId) {
 					User:     c.User.ID,
 					Post:     p.ID,
 					PostUser: p.UserID,
-					Liked:    false,
 					Time:     p.Created,
 				}
 
@@ -152,7 +242,6 @@ func PropagateSinglePostOnUserFollow(conn *services.Connection, ts *services.Tas
 		User:     user,
 		Post:     p.ID,
 		PostUser: p.UserID,
-		Liked:    false,
 		Time:     p.Created,
 	}
 
@@ -199,16 +288,17 @@ func PropagatePostsOnDeletion(c middleware.Context, postID bson.ObjectId) {
 	}
 }
 
-// PropagatePostOnLike sets the new like value for the user's timeline
-func PropagatePostOnLike(c middleware.Context, postID bson.ObjectId, liked bool) {
+// PropagatePostOnReaction sets the new reaction of the user to the post in the user's timeline,
+// an empty reaction means the user does not react to the post anymore
+func PropagatePostOnReaction(c middleware.Context, postID bson.ObjectId, reaction models.ReactionType) {
 	if !c.Config.Debug {
-		ID := c.Tasks.PushTask("post_like", c.User.ID.Hex(), postID.Hex(), liked)
+		ID := c.Tasks.PushTask("post_like", c.User.ID.Hex(), postID.Hex(), string(reaction))
 		c.AsyncQuery(func(conn *services.Connection) {
 			var t models.TimelineEntry
 
 			err := conn.Db.C("timelines").Find(bson.M{"post_id": postID, "user_id": c.User.ID}).One(&t)
 			if err == nil {
-				t.Liked = liked
+				t.Reaction = reaction
 				if _, err := conn.Db.C("timelines").UpsertId(t.ID, t); err == nil {
 					c.Tasks.TaskDone("post_like", ID)
 				}
@@ -338,3 +428,13 @@ func PropagatePostOnUserDeleted(c middleware.Context, userID bson.ObjectId) {
 		})
 	}
 }
+
+// postOwners returns the ids of the users whose followers may receive the post: its author and the user
+// whose profile it was posted in, if any
+func postOwners(post *models.Post) []bson.ObjectId {
+	if post.TargetID.Hex() != "" {
+		return []bson.ObjectId{post.UserID, post.TargetID}
+	}
+
+	return []bson.ObjectId{post.UserID}
+}
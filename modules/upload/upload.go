@@ -37,6 +37,9 @@ const (
 	maxFileSize = 10 * MB
 )
 
+// maxMemory is the maximum size of the uploaded files kept in memory while parsing a multipart request
+const maxMemory = 32 << 20
+
 func ToLocalImagePath(url string, config *services.Config) string {
 	return strings.Replace(url, config.WebStorePath, config.StorePath, -1)
 }
@@ -88,6 +91,68 @@ func RetrieveUploadedImage(r *http.Request, key string) (io.ReadCloser, error) {
 	return nil, errors.New("no file was uploaded")
 }
 
+// RetrieveUploadedImages returns all the uploaded files at the given key in the order they were sent along with
+// the error of each one of them, nil if the file can be stored, and their position in the form. Empty files,
+// sent by browsers for empty file inputs, are ignored.
+func RetrieveUploadedImages(r *http.Request, key string) ([]io.ReadCloser, []error, []int, error) {
+	if r.MultipartForm == nil {
+		if err := r.ParseMultipartForm(maxMemory); err != nil {
+			return nil, nil, nil, errors.New("no file was uploaded")
+		}
+	}
+
+	headers := r.MultipartForm.File[key]
+	files := make([]io.ReadCloser, 0, len(headers))
+	errs := make([]error, 0, len(headers))
+	positions := make([]int, 0, len(headers))
+
+	for i, h := range headers {
+		f, err := h.Open()
+		if err != nil {
+			files = append(files, nil)
+			positions = append(positions, i)
+			errs = append(errs, errors.New("invalid file"))
+			continue
+		}
+
+		size, err := f.Seek(0, os.SEEK_END)
+		if err == nil {
+			_, err = f.Seek(0, os.SEEK_SET)
+		}
+
+		if err != nil {
+			f.Close()
+			files = append(files, nil)
+			positions = append(positions, i)
+			errs = append(errs, errors.New("invalid file"))
+			continue
+		}
+
+		if size == 0 {
+			f.Close()
+			continue
+		}
+
+		if ByteSize(size) > maxFileSize {
+			f.Close()
+			files = append(files, nil)
+			positions = append(positions, i)
+			errs = append(errs, errors.New("file too large"))
+			continue
+		}
+
+		files = append(files, f)
+		positions = append(positions, i)
+		errs = append(errs, nil)
+	}
+
+	if len(files) == 0 {
+		return nil, nil, nil, errors.New("no file was uploaded")
+	}
+
+	return files, errs, positions, nil
+}
+
 // StoreImage stores in disk a file received with the request
 func StoreImage(file io.ReadCloser, options UploadOptions) (string, string, error) {
 	defer file.Close()
@@ -108,17 +173,20 @@ func StoreImage(file io.ReadCloser, options UploadOptions) (string, string, erro
 	iName := util.NewFileName(format)
 	imagePath := options.StorePath + iName
 	dst, err := os.Create(imagePath)
-	defer dst.Close()
 	if err != nil {
 		return "", "", err
 	}
+	defer dst.Close()
 
+	// No files are left behind if the image can't be completely stored
 	if err := writeFile(dst, img, format); err != nil {
+		os.Remove(imagePath)
 		return "", "", err
 	}
 
 	thumbnail, err := generateThumbnail(img, options)
 	if err != nil {
+		os.Remove(imagePath)
 		return "", "", err
 	}
 
@@ -126,11 +194,15 @@ func StoreImage(file io.ReadCloser, options UploadOptions) (string, string, erro
 	thumbnailPath := options.ThumbnailStorePath + tName
 	thumbDst, err := os.Create(thumbnailPath)
 	if err != nil {
+		os.Remove(imagePath)
 		return "", "", err
 	}
 
 	if err := writeFile(thumbDst, thumbnail, format); err != nil {
-		return "", "", nil
+		thumbDst.Close()
+		os.Remove(imagePath)
+		os.Remove(thumbnailPath)
+		return "", "", err
 	}
 
 	thumbDst.Close()
@@ -138,6 +210,12 @@ func StoreImage(file io.ReadCloser, options UploadOptions) (string, string, erro
 	return options.WebStorePath + iName, options.WebThumbnailStorePath + tName, nil
 }
 
+// RemoveImage removes from disk an image and its thumbnail given their urls
+func RemoveImage(imageURL, thumbnailURL string, config *services.Config) {
+	os.Remove(ToLocalImagePath(imageURL, config))
+	os.Remove(ToLocalThumbnailPath(thumbnailURL, config))
+}
+
 // CodeAndMessageForUploadError returns a code and an error message for the given error
 func CodeAndMessageForUploadError(err error) (int, string) {
 	var (
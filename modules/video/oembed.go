@@ -0,0 +1,121 @@
+package video
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/services/interfaces"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// OEmbedProvider is a provider whose video metadata is retrieved from an oEmbed endpoint
+type OEmbedProvider struct {
+	name     string
+	service  models.VideoService
+	patterns []*regexp.Regexp
+	endpoint string
+	embedURL string
+}
+
+// oEmbedResponse contains the fields of an oEmbed response used for videos
+type oEmbedResponse struct {
+	Title        string  `json:"title"`
+	ThumbnailURL string  `json:"thumbnail_url"`
+	Duration     float64 `json:"duration"`
+}
+
+// NewOEmbedProvider returns a provider for the videos whose URL matches one of the patterns.
+// The first non empty group of the patterns is the video ID and {id} in the embed URL is replaced with it.
+func NewOEmbedProvider(name string, service models.VideoService, patterns []string, endpoint, embedURL string) (*OEmbedProvider, error) {
+	if name == "" || len(patterns) == 0 || endpoint == "" || embedURL == "" {
+		return nil, errors.New("invalid video provider " + name)
+	}
+
+	p := &OEmbedProvider{
+		name:     name,
+		service:  service,
+		endpoint: endpoint,
+		embedURL: embedURL,
+	}
+
+	for _, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.NumSubexp() < 1 {
+			return nil, errors.New("the url patterns of video provider " + name + " must have a group for the ID")
+		}
+
+		p.patterns = append(p.patterns, r)
+	}
+
+	return p, nil
+}
+
+// Name returns the name of the provider
+func (p *OEmbedProvider) Name() string {
+	return p.name
+}
+
+// Service returns the service code of the provider
+func (p *OEmbedProvider) Service() models.VideoService {
+	return p.service
+}
+
+// Match returns the ID of the video if the URL matches one of the patterns of the provider
+func (p *OEmbedProvider) Match(URL string) (string, bool) {
+	for _, r := range p.patterns {
+		matches := r.FindStringSubmatch(URL)
+		for i := 1; i < len(matches); i++ {
+			if matches[i] != "" {
+				return matches[i], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Metadata retrieves the metadata of the video from the oEmbed endpoint of the provider
+func (p *OEmbedProvider) Metadata(fetcher interfaces.Fetcher, URL string) (*Metadata, error) {
+	var data oEmbedResponse
+
+	endpoint, err := url.Parse(p.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	query := endpoint.Query()
+	query.Set("url", URL)
+	query.Set("format", "json")
+	endpoint.RawQuery = query.Encode()
+
+	resp, err := fetcher.Get(endpoint.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, ErrVideoNotFound
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return &Metadata{
+		Title:        data.Title,
+		ThumbnailURL: data.ThumbnailURL,
+		Duration:     data.Duration,
+	}, nil
+}
+
+// EmbedURL returns the URL of the player of the video
+func (p *OEmbedProvider) EmbedURL(ID string) string {
+	return strings.Replace(p.embedURL, "{id}", url.QueryEscape(ID), -1)
+}
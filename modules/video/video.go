@@ -1,59 +1,157 @@
 package video
 
 import (
+	"errors"
 	"github.com/mvader/sunglasses/models"
-	"github.com/mvader/sunglasses/util"
-	"net/http"
-	"regexp"
+	"github.com/mvader/sunglasses/services"
+	"github.com/mvader/sunglasses/services/interfaces"
+	"strings"
 )
 
-// IsValidVideo determines if the video is valid or not
-func IsValidVideo(URL string) (bool, string, models.VideoService, string) {
-	var (
-		valid     bool
-		ID, title string
-		service   models.VideoService
-	)
+var (
+	// ErrUnsupportedVideo is returned when no provider supports the URL of a video
+	ErrUnsupportedVideo = errors.New("unsupported video provider")
+	// ErrVideoNotFound is returned when the provider does not have the video
+	ErrVideoNotFound = errors.New("video not found")
+)
+
+// Provider is a service videos can be posted from
+type Provider interface {
+	// Name returns the unique name of the provider
+	Name() string
+	// Service returns the service code of the provider, 0 for providers without one
+	Service() models.VideoService
+	// Match returns the ID of the video if the URL is a video of the provider
+	Match(URL string) (string, bool)
+	// Metadata retrieves the metadata of the video at the URL, ErrVideoNotFound if it does not exist
+	Metadata(fetcher interfaces.Fetcher, URL string) (*Metadata, error)
+	// EmbedURL returns the URL of the player of the video
+	EmbedURL(ID string) string
+}
+
+// Metadata is the information a provider gives about a video
+type Metadata struct {
+	Title        string
+	ThumbnailURL string
+	// Duration of the video in seconds
+	Duration float64
+}
+
+// Video is a video of a provider ready to be posted
+type Video struct {
+	Provider     string
+	Service      models.VideoService
+	ID           string
+	Title        string
+	EmbedURL     string
+	ThumbnailURL string
+	Duration     float64
+}
 
-	if valid, ID, service = isValidVideoURL(URL); !valid {
-		return false, "", 0, ""
+// Registry holds all the video providers videos can be posted from
+type Registry struct {
+	providers []Provider
+}
+
+// NewRegistry returns a registry with the built-in providers and the ones in the configuration
+func NewRegistry(config *services.Config) (*Registry, error) {
+	r := new(Registry)
+	for _, p := range builtinProviders() {
+		r.Register(p)
 	}
 
-	// Youtube does not reply with a not found status :-)
-	if service == models.VideoServiceYoutube {
-		URL = "http://gdata.youtube.com/feeds/api/videos/" + ID
+	for _, pc := range config.VideoProviders {
+		p, err := NewOEmbedProvider(pc.Name, 0, pc.URLPatterns, pc.OEmbedEndpoint, pc.EmbedURL)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Register(p)
 	}
 
-	resp, err := http.Get(URL)
-	if err != nil || resp.StatusCode != 200 {
-		return false, "", 0, ""
+	return r, nil
+}
+
+// Register adds a provider to the registry. A provider replaces the one with the same name, if any.
+func (r *Registry) Register(p Provider) {
+	for i, provider := range r.providers {
+		if provider.Name() == p.Name() {
+			r.providers[i] = p
+			return
+		}
 	}
 
-	title = util.ResponseTitle(resp)
+	r.providers = append(r.providers, p)
+}
 
-	return valid, ID, service, title
+// Find returns the provider of the video at the URL along with the ID of the video
+func (r *Registry) Find(URL string) (Provider, string, bool) {
+	for _, p := range r.providers {
+		if ID, ok := p.Match(URL); ok {
+			return p, ID, true
+		}
+	}
+
+	return nil, "", false
 }
 
-func isValidVideoURL(URL string) (bool, string, models.VideoService) {
-	if ID := extractIDFromVideoService(models.VideoServiceYoutubeRegexp, URL, 2, 3); ID != "" {
-		return true, ID, models.VideoServiceYoutube
+// Video retrieves the video at the given URL from its provider
+func (r *Registry) Video(fetcher interfaces.Fetcher, URL string) (*Video, error) {
+	p, ID, ok := r.Find(URL)
+	if !ok {
+		return nil, ErrUnsupportedVideo
+	}
+
+	meta, err := p.Metadata(fetcher, URL)
+	if err != nil {
+		return nil, err
 	}
 
-	if ID := extractIDFromVideoService(models.VideoServiceVimeoRegexp, URL, 1, 2); ID != "" {
-		return true, ID, models.VideoServiceVimeo
+	v := &Video{
+		Provider: p.Name(),
+		Service:  p.Service(),
+		ID:       ID,
+		Title:    meta.Title,
+		EmbedURL: p.EmbedURL(ID),
+		Duration: meta.Duration,
 	}
 
-	return false, "", 0
+	// The thumbnail is shown as is, so only web URLs are accepted
+	if strings.HasPrefix(meta.ThumbnailURL, "http://") || strings.HasPrefix(meta.ThumbnailURL, "https://") {
+		v.ThumbnailURL = meta.ThumbnailURL
+	}
+
+	return v, nil
 }
 
-func extractIDFromVideoService(service, URL string, idIndex, numMatches int) string {
-	r := regexp.MustCompile(service)
-	if r.MatchString(URL) {
-		matches := r.FindStringSubmatch(URL)
-		if len(matches) == numMatches {
-			return matches[idIndex]
-		}
+func builtinProviders() []Provider {
+	return []Provider{
+		mustOEmbedProvider("youtube", models.VideoServiceYoutube,
+			[]string{
+				`^https?://(?:www\.|m\.)?youtube\.com/watch\?(?:.*&)?v=([a-zA-Z0-9_-]+)`,
+				`^https?://youtu\.be/([a-zA-Z0-9_-]+)`,
+			},
+			"https://www.youtube.com/oembed",
+			"https://www.youtube-nocookie.com/embed/{id}?rel=0"),
+		mustOEmbedProvider("vimeo", models.VideoServiceVimeo,
+			[]string{`^https?://(?:www\.)?vimeo\.com/(?:video/)?([0-9]+)`},
+			"https://vimeo.com/api/oembed.json",
+			"https://player.vimeo.com/video/{id}"),
+		mustOEmbedProvider("dailymotion", models.VideoServiceDailymotion,
+			[]string{
+				`^https?://(?:www\.)?dailymotion\.com/video/([a-zA-Z0-9]+)`,
+				`^https?://dai\.ly/([a-zA-Z0-9]+)`,
+			},
+			"https://www.dailymotion.com/services/oembed",
+			"https://www.dailymotion.com/embed/video/{id}"),
+	}
+}
+
+func mustOEmbedProvider(name string, service models.VideoService, patterns []string, endpoint, embedURL string) Provider {
+	p, err := NewOEmbedProvider(name, service, patterns, endpoint, embedURL)
+	if err != nil {
+		panic(err)
 	}
 
-	return ""
+	return p
 }
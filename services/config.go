@@ -4,30 +4,62 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"time"
 )
 
 // Config gathers all the necessary data to run the app
 type Config struct {
-	URL                   string `json:"url"`
-	Port                  string `json:"port"`
-	StaticContentPath     string `json:"static_content_path"`
-	RedisAddress          string `json:"redis_address"`
-	SecretKey             string `json:"secret_key"`
-	SessionName           string `json:"session_name"`
-	DatabaseUrl           string `json:"database_url"`
-	DatabaseName          string `json:"database_name"`
-	Debug                 bool   `json:"debug"`
-	SecureCookies         bool   `json:"secure_cookies"`
-	StorePath             string `json:"store_path"`
-	ThumbnailStorePath    string `json:"thumbnail_store_path"`
-	WebStorePath          string `json:"web_store_path"`
-	WebThumbnailStorePath string `json:"web_thumbnail_store_path"`
-	LogsPath              string `json:"logs_path"`
-	UseHTTPS              bool   `json:"use_https"`
-	SSLCert               string `json:"ssl_cert"`
-	SSLKey                string `json:"ssl_key"`
+	URL                      string `json:"url"`
+	Port                     string `json:"port"`
+	StaticContentPath        string `json:"static_content_path"`
+	RedisAddress             string `json:"redis_address"`
+	SecretKey                string `json:"secret_key"`
+	SessionName              string `json:"session_name"`
+	DatabaseUrl              string `json:"database_url"`
+	DatabaseName             string `json:"database_name"`
+	Debug                    bool   `json:"debug"`
+	SecureCookies            bool   `json:"secure_cookies"`
+	StorePath                string `json:"store_path"`
+	ThumbnailStorePath       string `json:"thumbnail_store_path"`
+	WebStorePath             string `json:"web_store_path"`
+	WebThumbnailStorePath    string `json:"web_thumbnail_store_path"`
+	ExportsPath              string `json:"exports_path"`
+	LogsPath                 string `json:"logs_path"`
+	UseHTTPS                 bool   `json:"use_https"`
+	SSLCert                  string `json:"ssl_cert"`
+	SSLKey                   string `json:"ssl_key"`
+	AccountDeletionGraceDays int    `json:"account_deletion_grace_days"`
+	DraftTTLDays             int    `json:"draft_ttl_days"`
+	LinkPreviewTTLDays       int    `json:"link_preview_ttl_days"`
+	// Outbound requests to user supplied URLs, timeouts are in seconds
+	FetchConnectTimeout int      `json:"fetch_connect_timeout"`
+	FetchReadTimeout    int      `json:"fetch_read_timeout"`
+	FetchMaxBodySize    int64    `json:"fetch_max_body_size"`
+	FetchMaxRedirects   int      `json:"fetch_max_redirects"`
+	FetchAllowedHosts   []string `json:"fetch_allowed_hosts"`
+	// Video providers supported in addition to the built-in ones
+	VideoProviders []VideoProviderConfig `json:"video_providers"`
 }
 
+// VideoProviderConfig describes a video provider with an oEmbed endpoint, such as a PeerTube instance
+type VideoProviderConfig struct {
+	Name string `json:"name"`
+	// Regular expressions matching the URLs of the videos, the first non empty group must be the video ID
+	URLPatterns    []string `json:"url_patterns"`
+	OEmbedEndpoint string   `json:"oembed_endpoint"`
+	// URL of the player of a video, {id} is replaced with the video ID
+	EmbedURL string `json:"embed_url"`
+}
+
+// DefaultAccountDeletionGraceDays is the grace period for deleted accounts used if none is configured
+const DefaultAccountDeletionGraceDays = 30
+
+// DefaultDraftTTLDays is the time drafts are kept since their last update used if none is configured
+const DefaultDraftTTLDays = 30
+
+// DefaultLinkPreviewTTLDays is the time link previews are cached since they were fetched used if none is configured
+const DefaultLinkPreviewTTLDays = 7
+
 // NewConfig creates a new config struct
 func NewConfig(configPath string) (*Config, error) {
 	var config = new(Config)
@@ -50,3 +82,33 @@ func NewConfig(configPath string) (*Config, error) {
 
 	return config, nil
 }
+
+// AccountDeletionGracePeriod returns the time a deleted account can be restored before it's destroyed
+func (c *Config) AccountDeletionGracePeriod() time.Duration {
+	days := c.AccountDeletionGraceDays
+	if days <= 0 {
+		days = DefaultAccountDeletionGraceDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DraftTTL returns the time a draft is kept since its last update before it's removed along with its photos
+func (c *Config) DraftTTL() time.Duration {
+	days := c.DraftTTLDays
+	if days <= 0 {
+		days = DefaultDraftTTLDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// LinkPreviewTTL returns the time a link preview is cached since it was fetched before it's fetched again
+func (c *Config) LinkPreviewTTL() time.Duration {
+	days := c.LinkPreviewTTLDays
+	if days <= 0 {
+		days = DefaultLinkPreviewTTLDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
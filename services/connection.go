@@ -66,16 +66,23 @@ func (c *Connection) Close() {
 
 func createIndexes(conn *Connection) error {
 	indexes := map[string][]string{
-		"posts":         []string{"user_id"},
-		"albums":        []string{"user_id"},
-		"notifications": []string{"user_id"},
-		"tokens":        []string{"user_id", "hash"},
-		"requests":      []string{"user_to", "user_from"},
-		"follows":       []string{"user_to", "user_from"},
-		"reports":       []string{"user_id", "post_id"},
-		"blocks":        []string{"user_to", "user_from"},
-		"likes":         []string{"user_id", "post_id"},
-		"comments":      []string{"user_id", "post_id"},
+		"posts":             []string{"user_id", "hashtags", "scheduled"},
+		"albums":            []string{"user_id"},
+		"notifications":     []string{"user_id"},
+		"tokens":            []string{"user_id", "hash"},
+		"requests":          []string{"user_to", "user_from"},
+		"follows":           []string{"user_to", "user_from"},
+		"reports":           []string{"user_id", "post_id", "comment_id", "status"},
+		"blocks":            []string{"user_to", "user_from"},
+		"likes":             []string{"user_id", "post_id", "comment_id"},
+		"comments":          []string{"user_id", "post_id", "parent_id"},
+		"jobs":              []string{"user_id"},
+		"post_revisions":    []string{"post_id", "user_id"},
+		"comment_revisions": []string{"comment_id", "post_id", "user_id"},
+		"link_previews":     []string{"url", "fetched"},
+		"drafts":            []string{"user_id", "updated"},
+		"bookmarks":         []string{"user_id", "post_id"},
+		"poll_votes":        []string{"post_id", "user_id"},
 	}
 
 	for col, colIndexes := range indexes {
@@ -86,5 +93,19 @@ func createIndexes(conn *Connection) error {
 		}
 	}
 
+	// There can only be one document per user and post, or comment, in these collections
+	uniqueIndexes := map[string][]string{
+		"poll_votes": []string{"post_id", "user_id"},
+		"bookmarks":  []string{"user_id", "post_id"},
+		"reports":    []string{"user_id", "post_id", "comment_id"},
+		"likes":      []string{"user_id", "post_id", "comment_id"},
+	}
+
+	for col, key := range uniqueIndexes {
+		if err := conn.Db.C(col).EnsureIndex(mgo.Index{Key: key, Unique: true}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
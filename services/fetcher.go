@@ -0,0 +1,246 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFetchConnectTimeout is the time allowed to connect to a remote host if none is configured
+	DefaultFetchConnectTimeout = 5 * time.Second
+	// DefaultFetchReadTimeout is the time allowed between reads from a remote host if none is configured
+	DefaultFetchReadTimeout = 10 * time.Second
+	// DefaultFetchMaxBodySize is the maximum number of bytes read from a response if none is configured
+	DefaultFetchMaxBodySize = 10 << 20
+	// DefaultFetchMaxRedirects is the maximum number of redirects followed if none is configured
+	DefaultFetchMaxRedirects = 5
+)
+
+var (
+	// ErrAddressNotAllowed is returned when an URL resolves to a private or reserved address
+	ErrAddressNotAllowed = errors.New("address not allowed")
+	// ErrTooManyRedirects is returned when a request is redirected more times than allowed
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrInvalidScheme is returned when the URL is not an http or https URL
+	ErrInvalidScheme = errors.New("invalid scheme")
+
+	// Loopback, private, link-local, shared, multicast and otherwise reserved networks
+	reservedNetworks = parseNetworks(
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"192.88.99.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"64:ff9b::/96",
+		"100::/64",
+		"2001::/23",
+		"2001:db8::/32",
+		"fc00::/7",
+		"fe80::/10",
+		"ff00::/8",
+	)
+)
+
+// Fetcher performs the outbound HTTP requests to URLs supplied by users. Requests to hosts resolving
+// to private or reserved addresses are refused, unless the host is in the allowlist.
+type Fetcher struct {
+	client         *http.Client
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	maxBodySize    int64
+	maxRedirects   int
+	allowedHosts   map[string]bool
+	allowedNets    []*net.IPNet
+}
+
+// NewFetcher initializes the fetcher service
+func NewFetcher(config *Config) *Fetcher {
+	f := &Fetcher{
+		connectTimeout: time.Duration(config.FetchConnectTimeout) * time.Second,
+		readTimeout:    time.Duration(config.FetchReadTimeout) * time.Second,
+		maxBodySize:    config.FetchMaxBodySize,
+		maxRedirects:   config.FetchMaxRedirects,
+		allowedHosts:   make(map[string]bool),
+	}
+
+	if f.connectTimeout <= 0 {
+		f.connectTimeout = DefaultFetchConnectTimeout
+	}
+
+	if f.readTimeout <= 0 {
+		f.readTimeout = DefaultFetchReadTimeout
+	}
+
+	if f.maxBodySize <= 0 {
+		f.maxBodySize = DefaultFetchMaxBodySize
+	}
+
+	if f.maxRedirects <= 0 {
+		f.maxRedirects = DefaultFetchMaxRedirects
+	}
+
+	// Entries of the allowlist can be host names, IP addresses or networks in CIDR notation
+	for _, host := range config.FetchAllowedHosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if _, network, err := net.ParseCIDR(host); err == nil {
+			f.allowedNets = append(f.allowedNets, network)
+		} else if ip := net.ParseIP(host); ip != nil {
+			f.allowedNets = append(f.allowedNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else if host != "" {
+			f.allowedHosts[host] = true
+		}
+	}
+
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// Proxies from the environment would connect on our behalf without any check
+			Proxy:                 nil,
+			Dial:                  f.dial,
+			TLSHandshakeTimeout:   f.connectTimeout,
+			ResponseHeaderTimeout: f.readTimeout,
+		},
+		CheckRedirect: f.checkRedirect,
+	}
+
+	return f
+}
+
+// Get performs a GET request to the given URL. The body of the response is truncated after the
+// maximum body size and every read from it fails if the remote host takes too long to send data.
+func (f *Fetcher) Get(URL string) (*http.Response, error) {
+	u, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, ErrInvalidScheme
+	}
+
+	resp, err := f.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = &limitedBody{io.LimitReader(resp.Body, f.maxBodySize), resp.Body}
+
+	return resp, nil
+}
+
+// dial connects to the address only if the host is allowed and all its addresses are public.
+// The connection is made to the checked address so the host can't be resolved again to another one.
+func (f *Fetcher) dial(network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ips) == 0 {
+		return nil, ErrAddressNotAllowed
+	}
+
+	if !f.allowedHosts[strings.ToLower(host)] {
+		for _, ip := range ips {
+			if !f.isAllowedIP(ip) {
+				return nil, ErrAddressNotAllowed
+			}
+		}
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = net.DialTimeout(network, net.JoinHostPort(ip.String(), port), f.connectTimeout); err == nil {
+			return &timeoutConn{conn, f.readTimeout}, nil
+		}
+	}
+
+	return nil, err
+}
+
+// checkRedirect stops following redirects after the maximum number of them or to non http URLs
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > f.maxRedirects {
+		return ErrTooManyRedirects
+	}
+
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrInvalidScheme
+	}
+
+	return nil
+}
+
+// isAllowedIP determines if the fetcher can connect to the given IP address
+func (f *Fetcher) isAllowedIP(ip net.IP) bool {
+	for _, network := range f.allowedNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// timeoutConn is a connection that fails if a read or write does not complete in the given time
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	c.Conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Write(b)
+}
+
+// limitedBody is the body of a response that can only be read until a limit
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"labix.org/v2/mgo"
 	"labix.org/v2/mgo/bson"
+	"net/http"
 )
 
 type Saver interface {
@@ -18,3 +19,7 @@ type Conn interface {
 	Saver
 	Remover
 }
+
+type Fetcher interface {
+	Get(URL string) (*http.Response, error)
+}
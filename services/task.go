@@ -93,7 +93,7 @@ func (ts *TaskService) PushTask(task string, args ...interface{}) bson.ObjectId
 			return empty
 		}
 
-		_, err = ts.Do("HMSET", taskName, "user_id", args[0], "post_id", args[1], "liked", args[2], "has_children", false)
+		_, err = ts.Do("HMSET", taskName, "user_id", args[0], "post_id", args[1], "reaction", args[2], "has_children", false)
 		break
 	case "create_comment":
 		if len(args) < 1 {
@@ -116,6 +116,34 @@ func (ts *TaskService) PushTask(task string, args ...interface{}) bson.ObjectId
 
 		_, err = ts.Do("HMSET", taskName, "user_id", args[0], "has_children", false)
 		break
+	case "purge_user":
+		if len(args) < 1 {
+			return empty
+		}
+
+		_, err = ts.Do("HMSET", taskName, "user_id", args[0], "has_children", true)
+		break
+	case "album_change":
+		if len(args) < 1 {
+			return empty
+		}
+
+		_, err = ts.Do("HMSET", taskName, "album_id", args[0], "has_children", false)
+		break
+	case "change_posts_privacy":
+		if len(args) < 1 {
+			return empty
+		}
+
+		_, err = ts.Do("HMSET", taskName, "job_id", args[0], "has_children", false)
+		break
+	case "export_data":
+		if len(args) < 1 {
+			return empty
+		}
+
+		_, err = ts.Do("HMSET", taskName, "job_id", args[0], "has_children", false)
+		break
 	default:
 		return empty
 	}
@@ -163,6 +191,9 @@ func (ts *TaskService) PushFail(task string, taskID bson.ObjectId, args ...inter
 	case "delete_comment":
 		_, err = ts.Do("HMSET", name, "timeline", args[0])
 		break
+	case "purge_user":
+		_, err = ts.Do("HMSET", name, "file", args[0])
+		break
 	}
 
 	if err != nil {
@@ -177,7 +208,7 @@ func (ts *TaskService) TaskDone(task string, taskID bson.ObjectId) error {
 	var err error
 	taskName := task + ":" + taskID.Hex()
 
-	if task == "create_post" || task == "follow_user" || task == "create_comment" || task == "delete_comment" {
+	if task == "create_post" || task == "follow_user" || task == "create_comment" || task == "delete_comment" || task == "purge_user" {
 		v, err := ts.Do("SMEMBERS", taskName+":fail")
 		keys, err := redis.Strings(v, err)
 		if err != nil {
@@ -1,10 +1,14 @@
 package tests
 
 import (
+	"archive/zip"
 	"encoding/json"
 	. "github.com/mvader/sunglasses/error"
 	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
 	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	. "github.com/mvader/sunglasses/services"
 	"github.com/mvader/sunglasses/util"
 	. "github.com/smartystreets/goconvey/convey"
 	"labix.org/v2/mgo/bson"
@@ -14,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestCreateAccount(t *testing.T) {
@@ -249,6 +254,25 @@ func TestUpdateAccountInfo(t *testing.T) {
 			})
 		})
 
+		Convey("When invalid privacy settings are given for a field", func() {
+			testPutHandler(UpdateAccountInfo, func(req *http.Request) {
+				req.Header.Add("X-User-Token", token.Hash)
+				if req.PostForm == nil {
+					req.PostForm = make(url.Values)
+				}
+				req.PostForm.Add("work", "20th Century Fox")
+				req.PostForm.Add("privacy_work_type", "20")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidPrivacySettings)
+				So(errResp.Message, ShouldEqual, MsgInvalidPrivacySettings)
+			})
+		})
+
 		Convey("When everything is OK", func() {
 			testPutHandler(UpdateAccountInfo, func(req *http.Request) {
 				req.Header.Add("X-User-Token", token.Hash)
@@ -473,6 +497,7 @@ func TestDestroyAccount(t *testing.T) {
 		conn.C("likes").RemoveAll(nil)
 		conn.C("timelines").RemoveAll(nil)
 		conn.C("comments").RemoveAll(nil)
+		conn.C("bookmarks").RemoveAll(nil)
 	}()
 
 	Convey("Destroying an user account", t, func() {
@@ -519,6 +544,24 @@ func TestDestroyAccount(t *testing.T) {
 		err = post.Save(conn)
 		So(err, ShouldEqual, nil)
 
+		// Content of other users in the posts and the profile of the user
+		other := NewUser()
+		other.ID = bson.NewObjectId()
+
+		otherCmt := NewComment(other.ID, post.ID)
+		err = otherCmt.Save(conn)
+		So(err, ShouldEqual, nil)
+
+		bookmark := NewBookmark(other.ID, post.ID, "")
+		err = bookmark.Save(conn)
+		So(err, ShouldEqual, nil)
+
+		wallPost := NewPost(PostStatus, other)
+		wallPost.Text = "A post in the profile of the user"
+		wallPost.TargetID = user.ID
+		err = wallPost.Save(conn)
+		So(err, ShouldEqual, nil)
+
 		testPostHandler(LikePost, func(r *http.Request) {
 			r.Header.Add("X-User-Token", token.Hash)
 		}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
@@ -545,9 +588,45 @@ func TestDestroyAccount(t *testing.T) {
 				panic(err)
 			}
 			So(res.Code, ShouldEqual, 200)
-			So(errResp.Message, ShouldEqual, "User account has been successfully destroyed")
+			So(errResp.Message, ShouldEqual, "User account has been successfully deleted")
 		})
 
+		// The account is deactivated but its content is kept
+		var deleted User
+		err = conn.C("users").FindId(user.ID).One(&deleted)
+		So(err, ShouldEqual, nil)
+		So(deleted.Active, ShouldBeFalse)
+		So(deleted.Deleted, ShouldBeGreaterThan, 0)
+		So(UserExists(conn, user.ID), ShouldBeNil)
+
+		count, err := conn.C("tokens").Find(bson.M{"user_id": user.ID}).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 0)
+
+		count, err = conn.C("posts").Find(bson.M{"user_id": user.ID}).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 2)
+
+		// Accounts are not purged until the grace period passes
+		config, err := NewConfig("../config.sample.json")
+		So(err, ShouldEqual, nil)
+		config.StorePath = "../test_assets/"
+		config.ThumbnailStorePath = "../test_assets/"
+
+		ts, err := NewTaskService(config)
+		So(err, ShouldEqual, nil)
+
+		ctx := middleware.Context{Config: config, Conn: conn, Tasks: ts}
+		err = jobs.PurgeDeletedAccounts(ctx)
+		So(err, ShouldEqual, nil)
+
+		count, err = conn.C("users").FindId(user.ID).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+
+		err = jobs.PurgeAccount(ctx, &deleted)
+		So(err, ShouldEqual, nil)
+
 		files := 0
 		filepath.Walk("../test_assets/", func(path string, fi os.FileInfo, _ error) error {
 			if fi.Name() != ".DS_Store" && fi.Name() != "test_assets" {
@@ -558,10 +637,89 @@ func TestDestroyAccount(t *testing.T) {
 
 		So(files, ShouldEqual, 3)
 
-		for _, col := range []string{"tokens", "users", "comments", "timelines", "posts", "follows", "blocks", "notifications", "likes"} {
+		for _, col := range []string{"tokens", "users", "comments", "timelines", "posts", "follows", "blocks", "notifications", "likes", "bookmarks"} {
 			count, err := conn.C(col).Count()
 			So(count, ShouldEqual, 0)
 			So(err, ShouldEqual, nil)
 		}
 	})
 }
+
+func TestExportAccountData(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A fancy post"
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	FollowUser(user.ID, bson.NewObjectId(), conn)
+
+	user.EMail = "export@example.com"
+	if err := user.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.C("tokens").RemoveAll(nil)
+		conn.C("users").RemoveAll(nil)
+		conn.C("posts").RemoveAll(nil)
+		conn.C("follows").RemoveAll(nil)
+		conn.C("jobs").RemoveAll(nil)
+		conn.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Exporting the user data", t, func() {
+		testPostHandler(ExportAccountData, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+			So(res.Code, ShouldEqual, 202)
+		})
+
+		time.Sleep(500 * time.Millisecond)
+
+		var job Job
+		err := conn.C("jobs").Find(bson.M{"user_id": user.ID, "job_type": JobDataExport}).One(&job)
+		So(err, ShouldEqual, nil)
+		So(job.Status, ShouldEqual, JobFinished)
+		So(job.Expires, ShouldBeGreaterThan, float64(time.Now().Unix()))
+
+		archive, err := zip.OpenReader(job.File)
+		So(err, ShouldEqual, nil)
+		defer func() {
+			archive.Close()
+			os.Remove(job.File)
+		}()
+
+		files := make([]string, 0, len(archive.File))
+		for _, f := range archive.File {
+			files = append(files, f.Name)
+		}
+
+		So(files, ShouldContain, "profile.json")
+		So(files, ShouldContain, "posts.json")
+		So(files, ShouldContain, "follows.json")
+
+		for _, f := range archive.File {
+			if f.Name != "profile.json" {
+				continue
+			}
+
+			var profile map[string]interface{}
+			r, err := f.Open()
+			So(err, ShouldEqual, nil)
+			So(json.NewDecoder(r).Decode(&profile), ShouldEqual, nil)
+			r.Close()
+
+			So(profile["username"], ShouldEqual, user.Username)
+			So(profile["email"], ShouldEqual, "export@example.com")
+		}
+
+		count, err := conn.C("notifications").Find(bson.M{"user_id": user.ID, "job_id": job.ID}).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+	})
+}
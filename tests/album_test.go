@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCreateAlbum(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	defer func() {
+		conn.Db.C("albums").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Creating an album", t, func() {
+		Convey("When no title is given", func() {
+			testPostHandler(CreateAlbum, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidAlbumTitle)
+				So(errResp.Message, ShouldEqual, MsgInvalidAlbumTitle)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			testPostHandler(CreateAlbum, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("title", "Holidays")
+				r.PostForm.Add("description", "Photos of my holidays")
+				r.PostForm.Add("privacy_type", "2")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+
+				var album PhotoAlbum
+				err := conn.C("albums").Find(bson.M{"title": "Holidays"}).One(&album)
+				So(err, ShouldEqual, nil)
+				So(album.Description, ShouldEqual, "Photos of my holidays")
+				So(int(album.Privacy.Type), ShouldEqual, PrivacyFollowersOnly)
+			})
+		})
+	})
+}
+
+func TestAlbumPhotos(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	album := NewAlbum(user)
+	album.Title = "Private album"
+	album.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := album.Save(conn); err != nil {
+		panic(err)
+	}
+
+	photo := NewPost(PostPhoto, user)
+	photo.PhotoURL = "photo.jpg"
+	photo.Thumbnail = "photo_thumb.jpg"
+	photo.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := photo.Save(conn); err != nil {
+		panic(err)
+	}
+
+	status := NewPost(PostStatus, user)
+	status.Text = "Not a photo"
+	if err := status.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("albums").RemoveAll(nil)
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Adding photos to an album", t, func() {
+		Convey("When the post is not a photo", func() {
+			testPutHandler(AddPhotoToAlbum, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_id", status.ID.Hex())
+			}, conn, "/:id", "/"+album.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidAlbumPhoto)
+			})
+		})
+
+		Convey("When the album does not belong to the user", func() {
+			testPutHandler(AddPhotoToAlbum, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+				r.PostForm.Add("post_id", photo.ID.Hex())
+			}, conn, "/:id", "/"+album.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When everything is OK the album privacy constrains the photo", func() {
+			So(photo.CanBeAccessedBy(userTmp, conn), ShouldBeTrue)
+
+			testPutHandler(AddPhotoToAlbum, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_id", photo.ID.Hex())
+			}, conn, "/:id", "/"+album.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(photo.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.AlbumID, ShouldEqual, album.ID)
+			So((&p).CanBeAccessedBy(userTmp, conn), ShouldBeFalse)
+			So((&p).CanBeAccessedBy(user, conn), ShouldBeTrue)
+
+			var a PhotoAlbum
+			err = conn.C("albums").FindId(album.ID).One(&a)
+			So(err, ShouldEqual, nil)
+			So(a.CoverID, ShouldEqual, photo.ID)
+
+			(&a).LoadDisplayData(user, conn)
+			So(a.PhotosNum, ShouldEqual, 1)
+			So(a.Cover, ShouldEqual, "photo_thumb.jpg")
+
+			(&a).LoadDisplayData(userTmp, conn)
+			So(a.PhotosNum, ShouldEqual, 0)
+			So(a.Cover, ShouldEqual, "")
+		})
+
+		Convey("Removing the photo from the album", func() {
+			testPutHandler(RemovePhotoFromAlbum, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_id", photo.ID.Hex())
+			}, conn, "/:id", "/"+album.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(photo.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.AlbumID.Hex(), ShouldEqual, "")
+			So((&p).CanBeAccessedBy(userTmp, conn), ShouldBeTrue)
+		})
+
+		Convey("Listing the albums of an user", func() {
+			testGetHandler(GetUserAlbums, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:user_id", "/"+user.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["count"], ShouldEqual, 0)
+			})
+
+			testGetHandler(GetUserAlbums, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:user_id", "/"+user.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["count"], ShouldEqual, 1)
+			})
+		})
+	})
+}
@@ -6,6 +6,7 @@ import (
 	. "github.com/mvader/sunglasses/handlers"
 	. "github.com/mvader/sunglasses/middleware"
 	. "github.com/mvader/sunglasses/models"
+	. "github.com/mvader/sunglasses/services"
 	. "github.com/smartystreets/goconvey/convey"
 	"net/http"
 	"net/http/httptest"
@@ -81,6 +82,107 @@ func TestGetUserToken(t *testing.T) {
 	})
 }
 
+func TestRestoreDeletedAccount(t *testing.T) {
+	conn := getConnection()
+	defer conn.Session.Close()
+
+	user := new(User)
+	user.Username = "Jane Doe"
+	if err := user.SetPassword("testing"); err != nil {
+		panic(err)
+	}
+	user.Role = RoleUser
+	user.Deleted = float64(time.Now().Unix())
+	if err := user.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.C("users").RemoveAll(nil)
+		conn.C("tokens").RemoveAll(nil)
+	}()
+
+	Convey("Subject: Restoring a deleted account", t, func() {
+		Convey("When the user logs in without restoring the account the response code will be 403", func() {
+			testPostHandler(GetUserToken, func(req *http.Request) {
+				if req.PostForm == nil {
+					req.PostForm = make(url.Values)
+				}
+				req.PostForm.Add("username", "Jane Doe")
+				req.PostForm.Add("password", "testing")
+			}, conn, "/", "/", func(response *httptest.ResponseRecorder) {
+				So(response.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the user logs in restoring the account the response code will be 200 and the account will be active", func() {
+			testPostHandler(GetUserToken, func(req *http.Request) {
+				if req.PostForm == nil {
+					req.PostForm = make(url.Values)
+				}
+				req.PostForm.Add("username", "Jane Doe")
+				req.PostForm.Add("password", "testing")
+				req.PostForm.Add("restore", "true")
+			}, conn, "/", "/", func(response *httptest.ResponseRecorder) {
+				So(response.Code, ShouldEqual, 200)
+
+				var u User
+				if err := conn.C("users").FindId(user.ID).One(&u); err != nil {
+					panic(err)
+				}
+				So(u.Active, ShouldBeTrue)
+				So(u.Deleted, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the grace period has passed the account can't be restored", func() {
+			user.Active = false
+			user.Deleted = float64(time.Now().AddDate(0, 0, -DefaultAccountDeletionGraceDays-1).Unix())
+			if err := user.Save(conn); err != nil {
+				panic(err)
+			}
+
+			testPostHandler(GetUserToken, func(req *http.Request) {
+				if req.PostForm == nil {
+					req.PostForm = make(url.Values)
+				}
+				req.PostForm.Add("username", "Jane Doe")
+				req.PostForm.Add("password", "testing")
+				req.PostForm.Add("restore", "true")
+			}, conn, "/", "/", func(response *httptest.ResponseRecorder) {
+				So(response.Code, ShouldEqual, 400)
+			})
+		})
+
+		Convey("When the account is suspended it can't be restored", func() {
+			user.Active = false
+			user.Suspended = true
+			user.Deleted = float64(time.Now().Unix())
+			if err := user.Save(conn); err != nil {
+				panic(err)
+			}
+
+			testPostHandler(GetUserToken, func(req *http.Request) {
+				if req.PostForm == nil {
+					req.PostForm = make(url.Values)
+				}
+				req.PostForm.Add("username", "Jane Doe")
+				req.PostForm.Add("password", "testing")
+				req.PostForm.Add("restore", "true")
+			}, conn, "/", "/", func(response *httptest.ResponseRecorder) {
+				So(response.Code, ShouldEqual, 403)
+
+				var u User
+				if err := conn.C("users").FindId(user.ID).One(&u); err != nil {
+					panic(err)
+				}
+				So(u.Active, ShouldBeFalse)
+				So(u.Deleted, ShouldBeGreaterThan, 0)
+			})
+		})
+	})
+}
+
 func TestLogin(t *testing.T) {
 	conn := getConnection()
 	defer conn.Session.Close()
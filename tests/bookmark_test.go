@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBookmarks(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	public := NewPost(PostStatus, userTmp)
+	public.Text = "A public post"
+	public.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := public.Save(conn); err != nil {
+		panic(err)
+	}
+
+	other := NewPost(PostStatus, userTmp)
+	other.Text = "Another public post"
+	other.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := other.Save(conn); err != nil {
+		panic(err)
+	}
+
+	private := NewPost(PostStatus, userTmp)
+	private.Text = "A private post"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("bookmarks").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	bookmark := func(post *Post, collection string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(BookmarkPost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_id", post.ID.Hex())
+			r.PostForm.Add("collection", collection)
+		}, conn, "/", "/", testFunc)
+	}
+
+	listBookmarks := func(query string, testFunc func(map[string]interface{})) {
+		testGetHandler(ListBookmarks, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/", "/"+query, func(res *httptest.ResponseRecorder) {
+			var resp map[string]interface{}
+			if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+				panic(err)
+			}
+			So(res.Code, ShouldEqual, 200)
+			testFunc(resp)
+		})
+	}
+
+	Convey("Saving posts", t, func() {
+		Convey("When the user can't access the post", func() {
+			bookmark(private, "", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the collection name is too long", func() {
+			bookmark(public, strings.Repeat("a", MaxCollectionNameLength+1), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidCollectionName)
+			})
+		})
+
+		Convey("When the post is saved", func() {
+			conn.C("bookmarks").RemoveAll(nil)
+
+			bookmark(public, "", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			bookmark(other, "", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			listBookmarks("", func(resp map[string]interface{}) {
+				So(resp["count"], ShouldEqual, 2)
+			})
+
+			Convey("It can be moved to a collection", func() {
+				bookmark(public, "Recipes", func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				count, _ := conn.C("bookmarks").Find(nil).Count()
+				So(count, ShouldEqual, 2)
+
+				listBookmarks("?collection=Recipes", func(resp map[string]interface{}) {
+					So(resp["count"], ShouldEqual, 1)
+				})
+
+				testGetHandler(ListBookmarkCollections, func(r *http.Request) {
+					r.Header.Add("X-User-Token", token.Hash)
+				}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+					var resp map[string]interface{}
+					if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+						panic(err)
+					}
+					So(res.Code, ShouldEqual, 200)
+					So(resp["collections"].([]interface{})[0], ShouldEqual, "Recipes")
+				})
+			})
+
+			Convey("It can be removed", func() {
+				testHandler(func(m *martini.ClassicMartini) {
+					m.Delete("/:post_id", RemoveBookmark)
+				}, func(r *http.Request) {
+					r.Header.Add("X-User-Token", token.Hash)
+				}, conn, "/"+other.ID.Hex(), "DELETE", func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				}, false)
+
+				count, _ := conn.C("bookmarks").Find(nil).Count()
+				So(count, ShouldEqual, 1)
+			})
+
+			Convey("It is not listed when the user can't access it anymore", func() {
+				public.Privacy = PrivacySettings{Type: PrivacyNone}
+				if err := public.Save(conn); err != nil {
+					panic(err)
+				}
+
+				listBookmarks("", func(resp map[string]interface{}) {
+					So(resp["count"], ShouldEqual, 1)
+				})
+			})
+		})
+	})
+}
+
+func TestBookmarksPagination(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	// The 3 most recent bookmarks are of posts the user can't access anymore
+	for i := 0; i < 10; i++ {
+		post := NewPost(PostStatus, userTmp)
+		post.Text = "A saved post"
+		post.Privacy = PrivacySettings{Type: PrivacyPublic}
+		if i >= 7 {
+			post.Privacy = PrivacySettings{Type: PrivacyNone}
+		}
+
+		if err := post.Save(conn); err != nil {
+			panic(err)
+		}
+
+		b := NewBookmark(user.ID, post.ID, "")
+		b.Created += float64(i)
+		if err := b.Save(conn); err != nil {
+			panic(err)
+		}
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("bookmarks").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	listBookmarks := func(query string) float64 {
+		var resp map[string]interface{}
+		testGetHandler(ListBookmarks, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/", "/"+query, func(res *httptest.ResponseRecorder) {
+			if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+				panic(err)
+			}
+			So(res.Code, ShouldEqual, 200)
+		})
+
+		return resp["count"].(float64)
+	}
+
+	Convey("Paginating bookmarks", t, func() {
+		Convey("The pages are filled with accessible bookmarks", func() {
+			So(listBookmarks("?count=5"), ShouldEqual, 5)
+		})
+
+		Convey("The offset only counts accessible bookmarks", func() {
+			So(listBookmarks("?count=5&offset=5"), ShouldEqual, 2)
+		})
+	})
+}
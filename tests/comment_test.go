@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	. "github.com/mvader/sunglasses/error"
 	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
 	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/services"
 	. "github.com/smartystreets/goconvey/convey"
 	"labix.org/v2/mgo/bson"
 	"net/http"
@@ -253,3 +256,162 @@ func TestRemoveComment(t *testing.T) {
 		})
 	})
 }
+
+func TestEditComment(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A fancy post"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("comment_revisions").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	edit := func(token *Token, comment *Comment, text string, testFunc func(*httptest.ResponseRecorder)) {
+		testPutHandler(EditComment, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("comment_text", text)
+		}, conn, "/:comment_id", "/"+comment.ID.Hex(), testFunc)
+	}
+
+	Convey("Editing comments", t, func() {
+		conn.Db.C("comment_revisions").RemoveAll(nil)
+
+		comment := NewComment(user.ID, post.ID)
+		comment.Message = "A fancy comment"
+		if err := comment.Save(conn); err != nil {
+			panic(err)
+		}
+
+		Convey("When the comment does not belong to the user", func() {
+			edit(tokenTmp, comment, "An edited comment", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the comment is hidden until it is reviewed", func() {
+			comment.Hidden = true
+			if err := comment.Save(conn); err != nil {
+				panic(err)
+			}
+
+			edit(token, comment, "An edited comment", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the comment text is empty", func() {
+			edit(token, comment, "", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidCommentText)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			edit(token, comment, "An edited comment", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var c Comment
+			So(conn.C("comments").FindId(comment.ID).One(&c), ShouldEqual, nil)
+			So(c.Message, ShouldEqual, "An edited comment")
+			So(c.Edited, ShouldBeTrue)
+
+			testGetHandler(GetCommentRevisions, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:comment_id", "/"+comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+
+				revisions := resp["revisions"].([]interface{})
+				So(len(revisions), ShouldEqual, 1)
+				So(revisions[0].(map[string]interface{})["message"], ShouldEqual, "A fancy comment")
+			})
+		})
+	})
+}
+
+func TestDeleteReportedComment(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A fancy post"
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	comment := NewComment(user.ID, post.ID)
+	comment.Message = "A reported comment"
+	if err := comment.Save(conn); err != nil {
+		panic(err)
+	}
+
+	if err := conn.C("likes").Insert(Reaction{ID: bson.NewObjectId(), UserID: user.ID, PostID: post.ID, CommentID: comment.ID}); err != nil {
+		panic(err)
+	}
+
+	n := Notification{Type: NotificationCommentReacted, User: user.ID, PostID: post.ID, CommentID: comment.ID}
+	if err := (&n).Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("likes").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Deleting a reported comment removes its likes and notifications", t, func() {
+		err := jobs.DeleteComment(middleware.Context{Config: config, Conn: conn}, comment)
+		So(err, ShouldEqual, nil)
+
+		count, _ := conn.C("likes").Find(bson.M{"comment_id": comment.ID}).Count()
+		So(count, ShouldEqual, 0)
+
+		count, _ = conn.C("notifications").Find(bson.M{"comment_id": comment.ID}).Count()
+		So(count, ShouldEqual, 0)
+	})
+}
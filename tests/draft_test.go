@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
+	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/services"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDrafts(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("drafts").RemoveAll(nil)
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	var draft Draft
+
+	Convey("Working with drafts", t, func() {
+		Convey("When the post type is not valid", func() {
+			testPostHandler(CreateDraft, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_type", "poem")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidData)
+			})
+		})
+
+		Convey("Creating an empty draft", func() {
+			testPostHandler(CreateDraft, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("privacy_type", "4")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			err := conn.C("drafts").Find(bson.M{"user_id": user.ID}).One(&draft)
+			So(err, ShouldEqual, nil)
+			So(int(draft.Type), ShouldEqual, PostStatus)
+			So(int(draft.Privacy.Type), ShouldEqual, PrivacyNone)
+		})
+
+		Convey("Drafts can't be seen by other users", func() {
+			testGetHandler(ShowDraft, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:id", "/"+draft.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("Publishing an incomplete draft", func() {
+			testPostHandler(PublishDraft, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+draft.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidStatusText)
+			})
+
+			count, err := conn.C("drafts").FindId(draft.ID).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("Updating the draft", func() {
+			testPutHandler(UpdateDraft, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_text", "A status written in several sittings")
+			}, conn, "/:id", "/"+draft.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			testGetHandler(ListDrafts, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var resp struct {
+					Drafts []Draft `json:"drafts"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+
+				So(res.Code, ShouldEqual, 200)
+				So(len(resp.Drafts), ShouldEqual, 1)
+				So(resp.Drafts[0].Text, ShouldEqual, "A status written in several sittings")
+				So(int(resp.Drafts[0].Privacy.Type), ShouldEqual, PrivacyNone)
+			})
+		})
+
+		Convey("Publishing the draft", func() {
+			testPostHandler(PublishDraft, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+draft.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"user_id": user.ID}).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Text, ShouldEqual, "A status written in several sittings")
+			So(int(p.Privacy.Type), ShouldEqual, PrivacyNone)
+
+			count, err := conn.C("drafts").FindId(draft.ID).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("Publishing a poll draft with a content warning", func() {
+			conn.Db.C("posts").RemoveAll(nil)
+
+			var pollDraft Draft
+			testPostHandler(CreateDraft, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_type", "poll")
+				r.PostForm.Add("post_text", "Tabs or spaces?")
+				r.PostForm.Add("poll_options", "Tabs")
+				r.PostForm.Add("poll_options", "")
+				r.PostForm.Add("poll_options", "Spaces")
+				r.PostForm.Add("poll_duration", "3600")
+				r.PostForm.Add("content_warning", "Flame war")
+				r.PostForm.Add("sensitive", "true")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var resp struct {
+					Draft Draft `json:"draft"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+				So(resp.Draft.PollOptions, ShouldResemble, []string{"Tabs", "Spaces"})
+				pollDraft = resp.Draft
+			})
+
+			testPostHandler(PublishDraft, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+pollDraft.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"user_id": user.ID}).One(&p)
+			So(err, ShouldEqual, nil)
+			So(int(p.Type), ShouldEqual, PostPoll)
+			So(p.ContentWarning, ShouldEqual, "Flame war")
+			So(p.Sensitive, ShouldBeTrue)
+			So(len(p.Poll.Options), ShouldEqual, 2)
+			So(p.Poll.Closes, ShouldBeGreaterThan, float64(time.Now().Unix()+3500))
+			So(p.Poll.Closes, ShouldBeLessThanOrEqualTo, float64(time.Now().Unix()+3600))
+		})
+	})
+}
+
+func TestDeleteAbandonedDrafts(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	abandoned := NewDraft(PostStatus, user)
+	abandoned.Updated = float64(time.Now().Add(-config.DraftTTL()).Unix() - 10)
+	if err := abandoned.Save(conn); err != nil {
+		panic(err)
+	}
+
+	recent := NewDraft(PostStatus, user)
+	if err := recent.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("drafts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Deleting abandoned drafts", t, func() {
+		err := jobs.DeleteAbandonedDrafts(middleware.Context{Config: config, Conn: conn})
+		So(err, ShouldEqual, nil)
+
+		count, err := conn.C("drafts").FindId(abandoned.ID).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 0)
+
+		count, err = conn.C("drafts").FindId(recent.ID).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"fmt"
+	. "github.com/mvader/sunglasses/services"
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetcher(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("a", 2048))
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/redirect/"))
+		if n <= 0 {
+			http.Redirect(w, r, "/", 302)
+			return
+		}
+
+		http.Redirect(w, r, "/redirect/"+strconv.Itoa(n-1), 302)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", 302)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		fmt.Fprint(w, "too late")
+	})
+
+	config := &Config{
+		FetchReadTimeout:  1,
+		FetchMaxBodySize:  1024,
+		FetchMaxRedirects: 2,
+		FetchAllowedHosts: []string{"127.0.0.1"},
+	}
+	fetcher := NewFetcher(config)
+
+	Convey("Fetching remote URLs", t, func() {
+		Convey("When the host resolves to a private address", func() {
+			_, err := NewFetcher(&Config{}).Get(server.URL)
+			So(err, ShouldNotEqual, nil)
+			So(err.Error(), ShouldContainSubstring, ErrAddressNotAllowed.Error())
+		})
+
+		Convey("When the host is in the allowlist", func() {
+			resp, err := fetcher.Get(server.URL)
+			So(err, ShouldEqual, nil)
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldEqual, nil)
+			So(string(body), ShouldEqual, "hello")
+		})
+
+		Convey("When the URL is not an http URL", func() {
+			_, err := fetcher.Get("file:///etc/passwd")
+			So(err, ShouldEqual, ErrInvalidScheme)
+		})
+
+		Convey("When the body is larger than the limit", func() {
+			resp, err := fetcher.Get(server.URL + "/large")
+			So(err, ShouldEqual, nil)
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			So(err, ShouldEqual, nil)
+			So(len(body), ShouldEqual, 1024)
+		})
+
+		Convey("When the number of redirects is within the limit", func() {
+			resp, err := fetcher.Get(server.URL + "/redirect/1")
+			So(err, ShouldEqual, nil)
+			resp.Body.Close()
+			So(resp.StatusCode, ShouldEqual, 200)
+		})
+
+		Convey("When there are too many redirects", func() {
+			_, err := fetcher.Get(server.URL + "/redirect/5")
+			So(err, ShouldNotEqual, nil)
+			So(err.Error(), ShouldContainSubstring, ErrTooManyRedirects.Error())
+		})
+
+		Convey("When a redirect points to a private address", func() {
+			_, err := fetcher.Get(server.URL + "/private")
+			So(err, ShouldNotEqual, nil)
+			So(err.Error(), ShouldContainSubstring, ErrAddressNotAllowed.Error())
+		})
+
+		Convey("When the server takes too long to reply", func() {
+			_, err := fetcher.Get(server.URL + "/slow")
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseHashtags(t *testing.T) {
+	Convey("Parsing hashtags", t, func() {
+		tags := ParseHashtags("#Go is #fun, #go#not and #2014 &#39; #años", "more #fun")
+		So(tags, ShouldResemble, []string{"go", "fun", "años"})
+	})
+}
+
+func TestMentions(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	mentioned := NewUser()
+	mentioned.Username = "mentioned"
+	if err := mentioned.Save(conn); err != nil {
+		panic(err)
+	}
+
+	blocked := NewUser()
+	blocked.Username = "blocked"
+	if err := blocked.Save(conn); err != nil {
+		panic(err)
+	}
+	BlockUser(blocked.ID, user.ID, conn)
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("blocks").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	post := func(text, privacy string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_text", text)
+			r.PostForm.Add("privacy_type", privacy)
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Mentioning users in posts", t, func() {
+		Convey("When the mentioned user can't access the post", func() {
+			post("Only for me @mentioned", "4", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			count, err := conn.C("notifications").Find(bson.M{"user_id": mentioned.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 0)
+		})
+
+		Convey("When the post is public", func() {
+			post("Hi @mentioned, @blocked and @nobody #hello", "1", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"hashtags": "hello"}).One(&p)
+			So(err, ShouldEqual, nil)
+			So(len(p.Mentions), ShouldEqual, 2)
+
+			count, err := conn.C("notifications").Find(bson.M{
+				"user_id":           mentioned.ID,
+				"post_id":           p.ID,
+				"notification_type": NotificationMentioned,
+			}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+
+			count, err = conn.C("notifications").Find(bson.M{"user_id": blocked.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestGetHashtagPosts(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	public := NewPost(PostStatus, userTmp)
+	public.Text = "A public #post"
+	public.Privacy = PrivacySettings{Type: PrivacyPublic}
+	public.Hashtags = ParseHashtags(public.Text)
+	if err := public.Save(conn); err != nil {
+		panic(err)
+	}
+
+	private := NewPost(PostStatus, userTmp)
+	private.Text = "A private #post"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	private.Hashtags = ParseHashtags(private.Text)
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		user.Remove(conn)
+		userTmp.Remove(conn)
+		token.Remove(conn)
+		conn.Session.Close()
+	}()
+
+	Convey("Listing the posts of a hashtag", t, func() {
+		testGetHandler(GetHashtagPosts, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/:tag", "/Post", func(res *httptest.ResponseRecorder) {
+			var resp struct {
+				Hashtag string `json:"hashtag"`
+				Posts   []Post `json:"posts"`
+			}
+			if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+				panic(err)
+			}
+
+			So(res.Code, ShouldEqual, 200)
+			So(resp.Hashtag, ShouldEqual, "post")
+			So(len(resp.Posts), ShouldEqual, 1)
+			So(resp.Posts[0].ID.Hex(), ShouldEqual, public.ID.Hex())
+		})
+	})
+}
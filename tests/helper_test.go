@@ -8,6 +8,7 @@ import (
 	"github.com/martini-contrib/render"
 	. "github.com/mvader/sunglasses/middleware"
 	"github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/video"
 	. "github.com/mvader/sunglasses/services"
 	"io"
 	"mime/multipart"
@@ -57,28 +58,30 @@ func testDeleteHandler(handler, middleware martini.Handler, conn *Connection, ha
 	}, middleware, conn, reqUrl, "DELETE", testFunc, false)
 }
 
-func uploadFile(file, key, url string) (*http.Request, error) {
+func uploadFiles(files []string, key, url string) (*http.Request, error) {
 	var b bytes.Buffer
 	contentType := "application/octet-stream"
 
-	if file != "" {
+	if len(files) > 0 {
 		w := multipart.NewWriter(&b)
 
-		f, err := os.Open(file)
-		if err != nil {
-			return nil, err
-		}
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, err
+			}
 
-		fw, err := w.CreateFormFile(key, file)
-		if err != nil {
-			return nil, err
-		}
+			fw, err := w.CreateFormFile(key, file)
+			if err != nil {
+				return nil, err
+			}
 
-		if _, err = io.Copy(fw, f); err != nil {
-			return nil, err
+			if _, err = io.Copy(fw, f); err != nil {
+				return nil, err
+			}
 		}
 
-		if fw, err = w.CreateFormFile(key, file); err != nil {
+		if _, err := w.CreateFormFile(key, files[0]); err != nil {
 			return nil, err
 		}
 
@@ -99,12 +102,21 @@ func uploadFile(file, key, url string) (*http.Request, error) {
 }
 
 func testUploadFileHandler(file, key, url string, handler martini.Handler, conn *Connection, middleware func(*http.Request), testFunc func(*httptest.ResponseRecorder)) {
+	var files []string
+	if file != "" {
+		files = []string{file}
+	}
+
+	testUploadFilesHandler(files, key, url, handler, conn, middleware, testFunc)
+}
+
+func testUploadFilesHandler(files []string, key, url string, handler martini.Handler, conn *Connection, middleware func(*http.Request), testFunc func(*httptest.ResponseRecorder)) {
 	config, err := NewConfig("../config.sample.json")
 	if err != nil {
 		panic(err)
 	}
 
-	req, err := uploadFile(file, key, url)
+	req, err := uploadFiles(files, key, url)
 	if err != nil {
 		panic(err)
 	}
@@ -121,6 +133,8 @@ func testUploadFileHandler(file, key, url string, handler martini.Handler, conn
 	m.Map(conn)
 	m.Map(config)
 	m.Map(ts)
+	m.Map(NewFetcher(config))
+	m.Map(videoRegistry(config))
 	m.Use(render.Renderer())
 	store := sessions.NewCookieStore([]byte(config.SecretKey))
 	m.Map(store)
@@ -150,6 +164,7 @@ func testHandler(methHandler func(*martini.ClassicMartini), middleware martini.H
 
 	config.StorePath = "../test_assets/"
 	config.ThumbnailStorePath = "../test_assets/"
+	config.ExportsPath = "../test_assets/"
 
 	if overrideDebug {
 		config.Debug = false
@@ -160,6 +175,8 @@ func testHandler(methHandler func(*martini.ClassicMartini), middleware martini.H
 	m.Map(conn)
 	m.Map(config)
 	m.Map(ts)
+	m.Map(NewFetcher(config))
+	m.Map(videoRegistry(config))
 	m.Use(render.Renderer())
 	store := sessions.NewCookieStore([]byte(config.SecretKey))
 	m.Map(store)
@@ -173,6 +190,15 @@ func testHandler(methHandler func(*martini.ClassicMartini), middleware martini.H
 	testFunc(response)
 }
 
+func videoRegistry(config *Config) *video.Registry {
+	videos, err := video.NewRegistry(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return videos
+}
+
 func getConnection() *Connection {
 	config, err := NewConfig("../config.sample.json")
 	if err != nil {
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShowJob(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	job := NewJob(JobPostsPrivacyChange, user.ID)
+	job.Total = 10
+	job.Processed = 4
+	if err := job.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("jobs").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Showing the progress of a job", t, func() {
+		Convey("When invalid id is passed", func() {
+			testGetHandler(ShowJob, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/a", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidData)
+			})
+		})
+
+		Convey("When the job does not exist", func() {
+			testGetHandler(ShowJob, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+bson.NewObjectId().Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 404)
+			})
+		})
+
+		Convey("When the job does not belong to the user", func() {
+			testGetHandler(ShowJob, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:id", "/"+job.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			testGetHandler(ShowJob, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+job.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["job"].(map[string]interface{})["processed"].(float64), ShouldEqual, float64(4))
+				So(resp["job"].(map[string]interface{})["total"].(float64), ShouldEqual, float64(10))
+			})
+		})
+	})
+}
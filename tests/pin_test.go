@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPinPost(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	posts := make([]*Post, 5)
+	for i := range posts {
+		posts[i] = NewPost(PostStatus, user)
+		posts[i].Text = "A fancy post"
+		posts[i].Created = float64(time.Now().Unix() - int64(len(posts)-i))
+		posts[i].Privacy = PrivacySettings{Type: PrivacyPublic}
+		if err := posts[i].Save(conn); err != nil {
+			panic(err)
+		}
+	}
+
+	private := NewPost(PostStatus, user)
+	private.Text = "A private post"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	pin := func(handler interface{}, t *Token, post *Post, testFunc func(*httptest.ResponseRecorder)) {
+		testPutHandler(handler, func(r *http.Request) {
+			r.Header.Add("X-User-Token", t.Hash)
+		}, conn, "/:id", "/"+post.ID.Hex(), testFunc)
+	}
+
+	Convey("Pinning posts", t, func() {
+		Convey("When the post belongs to another user", func() {
+			pin(PinPost, tokenTmp, posts[0], func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the post can only be seen by the user", func() {
+			pin(PinPost, token, private, func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeCantPinPost)
+			})
+		})
+
+		Convey("When the post can be pinned", func() {
+			pin(PinPost, token, posts[0], func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(posts[0].ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Pinned, ShouldBeGreaterThan, 0)
+
+			Convey("The pinned post is the first post of the profile", func() {
+				testGetHandler(ShowUserProfile, func(r *http.Request) {
+					r.Header.Add("X-User-Token", tokenTmp.Hash)
+				}, conn, "/:username", "/testing", func(res *httptest.ResponseRecorder) {
+					var resp map[string]interface{}
+					if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+						panic(err)
+					}
+					So(res.Code, ShouldEqual, 200)
+					So(resp["posts_count"], ShouldEqual, 5)
+
+					first := resp["posts"].([]interface{})[0].(map[string]interface{})
+					So(first["id"], ShouldEqual, posts[0].ID.Hex())
+				})
+			})
+
+			Convey("The post is unpinned when it is hidden", func() {
+				testPutHandler(ChangePostPrivacy, func(r *http.Request) {
+					if r.PostForm == nil {
+						r.PostForm = make(url.Values)
+					}
+					r.Header.Add("X-User-Token", token.Hash)
+					r.PostForm.Add("privacy_type", "4")
+				}, conn, "/:id", "/"+posts[0].ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				err := conn.C("posts").FindId(posts[0].ID).One(&p)
+				So(err, ShouldEqual, nil)
+				So(p.Pinned, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When there are too many pinned posts", func() {
+			for i := 1; i <= MaxPinnedPosts; i++ {
+				pin(PinPost, token, posts[i], func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+			}
+
+			pin(PinPost, token, posts[MaxPinnedPosts+1], func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeTooManyPinnedPosts)
+			})
+
+			Convey("The posts can be unpinned", func() {
+				pin(UnpinPost, token, posts[1], func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				pin(PinPost, token, posts[MaxPinnedPosts+1], func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+			})
+		})
+	})
+}
@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
+	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/services"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCreatePoll(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	createPoll := func(options []string, duration string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_type", "poll")
+			r.PostForm.Add("post_text", "Which one?")
+			r.PostForm.Add("privacy_type", "1")
+			r.PostForm.Add("poll_duration", duration)
+			for _, o := range options {
+				r.PostForm.Add("poll_options", o)
+			}
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Creating polls", t, func() {
+		Convey("When the poll has too few options", func() {
+			createPoll([]string{"Only one"}, "", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidPoll)
+			})
+		})
+
+		Convey("When an option is empty", func() {
+			createPoll([]string{"One", " "}, "", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 400)
+			})
+		})
+
+		Convey("When the duration is not valid", func() {
+			createPoll([]string{"One", "Two"}, "60", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidPollDuration)
+			})
+		})
+
+		Convey("When the poll is valid", func() {
+			createPoll([]string{"One", "Two", "Three"}, "3600", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+
+				poll := resp["post"].(map[string]interface{})["poll"].(map[string]interface{})
+				So(len(poll["options"].([]interface{})), ShouldEqual, 3)
+				So(poll["closes"], ShouldBeGreaterThan, float64(time.Now().Unix()+3500))
+			})
+		})
+	})
+}
+
+func TestVotePoll(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	poll := NewPost(PostPoll, userTmp)
+	poll.Text = "Which one?"
+	poll.Privacy = PrivacySettings{Type: PrivacyPublic}
+	poll.Poll = NewPoll([]string{"One", "Two", "Three"}, false, float64(time.Now().Unix()+3600))
+	if err := poll.Save(conn); err != nil {
+		panic(err)
+	}
+
+	private := NewPost(PostPoll, userTmp)
+	private.Text = "Which one?"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	private.Poll = NewPoll([]string{"One", "Two"}, false, float64(time.Now().Unix()+3600))
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	closed := NewPost(PostPoll, userTmp)
+	closed.Text = "Which one?"
+	closed.Privacy = PrivacySettings{Type: PrivacyPublic}
+	closed.Poll = NewPoll([]string{"One", "Two"}, false, float64(time.Now().Unix()-10))
+	if err := closed.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("poll_votes").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	vote := func(post *Post, options []string, testFunc func(*httptest.ResponseRecorder)) {
+		testPutHandler(VotePoll, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			for _, o := range options {
+				r.PostForm.Add("options", o)
+			}
+		}, conn, "/:id", "/"+post.ID.Hex(), testFunc)
+	}
+
+	Convey("Voting in polls", t, func() {
+		Convey("When the user can't access the poll", func() {
+			vote(private, []string{"0"}, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the poll is closed", func() {
+			vote(closed, []string{"0"}, func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 403)
+				So(errResp.Code, ShouldEqual, CodePollClosed)
+			})
+		})
+
+		Convey("When more than one option is voted in a single choice poll", func() {
+			vote(poll, []string{"0", "1"}, func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidVote)
+			})
+		})
+
+		Convey("The results are hidden until the user votes", func() {
+			posts := []Post{*poll}
+			posts[0].Poll.Options[0].Votes = 4
+			SetPollVotes(posts, user, conn)
+			So(posts[0].Poll.ResultsHidden, ShouldBeTrue)
+			So(posts[0].Poll.Options[0].Votes, ShouldEqual, 0)
+		})
+
+		Convey("When the vote is valid", func() {
+			conn.Db.C("poll_votes").RemoveAll(nil)
+			if err := poll.Save(conn); err != nil {
+				panic(err)
+			}
+
+			vote(poll, []string{"2"}, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(poll.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Poll.Voters, ShouldEqual, 1)
+			So(p.Poll.Options[2].Votes, ShouldEqual, 1)
+
+			posts := []Post{p}
+			SetPollVotes(posts, user, conn)
+			So(posts[0].Poll.ResultsHidden, ShouldBeFalse)
+			So(posts[0].Poll.Voted, ShouldResemble, []int{2})
+
+			Convey("The database rejects a second vote of the user", func() {
+				err := conn.C("poll_votes").Insert(PollVote{
+					ID:      bson.NewObjectId(),
+					PostID:  poll.ID,
+					UserID:  user.ID,
+					Options: []int{1},
+				})
+				So(mgo.IsDup(err), ShouldBeTrue)
+			})
+
+			Convey("The user can't vote again", func() {
+				vote(poll, []string{"1"}, func(res *httptest.ResponseRecorder) {
+					var errResp errorResponse
+					if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+						panic(err)
+					}
+					So(res.Code, ShouldEqual, 403)
+					So(errResp.Code, ShouldEqual, CodeAlreadyVoted)
+				})
+			})
+		})
+	})
+}
+
+func TestClosePolls(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	finished := NewPost(PostPoll, user)
+	finished.Text = "Which one?"
+	finished.Poll = NewPoll([]string{"One", "Two"}, false, float64(time.Now().Unix()-10))
+	if err := finished.Save(conn); err != nil {
+		panic(err)
+	}
+
+	open := NewPost(PostPoll, user)
+	open.Text = "Which one?"
+	open.Poll = NewPoll([]string{"One", "Two"}, false, float64(time.Now().Unix()+3600))
+	if err := open.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Closing finished polls", t, func() {
+		err := jobs.ClosePolls(middleware.Context{Config: config, Conn: conn})
+		So(err, ShouldEqual, nil)
+
+		var p Post
+		So(conn.C("posts").FindId(finished.ID).One(&p), ShouldEqual, nil)
+		So(p.Poll.Closed, ShouldBeTrue)
+
+		So(conn.C("posts").FindId(open.ID).One(&p), ShouldEqual, nil)
+		So(p.Poll.Closed, ShouldBeFalse)
+
+		count, _ := conn.C("notifications").Find(bson.M{"notification_type": NotificationPollClosed, "post_id": finished.ID}).Count()
+		So(count, ShouldEqual, 1)
+
+		Convey("The author is only notified once", func() {
+			err := jobs.ClosePolls(middleware.Context{Config: config, Conn: conn})
+			So(err, ShouldEqual, nil)
+
+			count, _ := conn.C("notifications").Find(bson.M{"notification_type": NotificationPollClosed, "post_id": finished.ID}).Count()
+			So(count, ShouldEqual, 1)
+		})
+	})
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	. "github.com/mvader/sunglasses/error"
 	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
 	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/services"
 	"github.com/mvader/sunglasses/util"
 	. "github.com/smartystreets/goconvey/convey"
 	"labix.org/v2/mgo/bson"
@@ -307,6 +310,179 @@ func TestPostPhoto(t *testing.T) {
 				So(res.Code, ShouldEqual, 201)
 			})
 		})
+
+		Convey("When several photos are uploaded", func() {
+			testUploadFilesHandler([]string{"../test_assets/gopher.jpg", "../test_assets/gopher.jpg"}, "post_picture", "/", CreatePost, conn, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.PostForm.Add("post_type", "photo")
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("photo_captions", "First")
+				r.PostForm.Add("photo_captions", "Second")
+			}, func(res *httptest.ResponseRecorder) {
+				var resp struct {
+					Post Post `json:"post"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+				So(len(resp.Post.Photos), ShouldEqual, 2)
+				So(resp.Post.Photos[0].Caption, ShouldEqual, "First")
+				So(resp.Post.Photos[1].Caption, ShouldEqual, "Second")
+				So(resp.Post.PhotoURL, ShouldEqual, resp.Post.Photos[0].URL)
+			})
+		})
+
+		Convey("When one of the photos is invalid none of them is stored", func() {
+			countFiles := func() int {
+				files := 0
+				filepath.Walk("../test_assets/", func(path string, _ os.FileInfo, _ error) error {
+					files++
+					return nil
+				})
+				return files
+			}
+
+			before := countFiles()
+
+			testUploadFilesHandler([]string{"../test_assets/gopher.jpg", "../test_assets/file.md"}, "post_picture", "/", CreatePost, conn, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.PostForm.Add("post_type", "photo")
+				r.Header.Add("X-User-Token", token.Hash)
+			}, func(res *httptest.ResponseRecorder) {
+				var resp struct {
+					Files []int `json:"files"`
+					Codes []int `json:"codes"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(resp.Files, ShouldResemble, []int{1})
+				So(resp.Codes, ShouldResemble, []int{CodeInvalidFile})
+			})
+
+			So(countFiles(), ShouldEqual, before)
+		})
+
+		Convey("When an empty file input is sent the failed files keep their position", func() {
+			f, err := os.Create("../test_assets/empty.jpg")
+			if err != nil {
+				panic(err)
+			}
+			f.Close()
+			defer os.Remove("../test_assets/empty.jpg")
+
+			testUploadFilesHandler([]string{"../test_assets/empty.jpg", "../test_assets/file.md"}, "post_picture", "/", CreatePost, conn, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.PostForm.Add("post_type", "photo")
+				r.Header.Add("X-User-Token", token.Hash)
+			}, func(res *httptest.ResponseRecorder) {
+				var resp struct {
+					Files []int `json:"files"`
+				}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(resp.Files, ShouldResemble, []int{1})
+			})
+		})
+	})
+}
+
+func TestWallPosts(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	userTmp.Settings.AllowPostsInMyProfile = false
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("blocks").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	postOnWall := func(token string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token)
+			r.PostForm.Add("post_text", "Hi there!")
+			r.PostForm.Add("target_user_id", userTmp.ID.Hex())
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Posting on the profile of another user", t, func() {
+		Convey("When the user does not allow posts in the profile", func() {
+			postOnWall(token.Hash, func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 403)
+				So(errResp.Code, ShouldEqual, CodeCantPostOnProfile)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			userTmp.Settings.AllowPostsInMyProfile = true
+			userTmp.Settings.NotifyPostsInMyProfile = true
+			if err := userTmp.Save(conn); err != nil {
+				panic(err)
+			}
+
+			postOnWall(token.Hash, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"target_id": userTmp.ID}).One(&p)
+			So(err, ShouldEqual, nil)
+
+			count, err := conn.C("notifications").Find(bson.M{"user_id": userTmp.ID, "post_id": p.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+
+			Convey("The owner of the profile can delete it", func() {
+				testDeleteHandler(DeletePost, func(r *http.Request) {
+					r.Header.Add("X-User-Token", tokenTmp.Hash)
+				}, conn, "/:id", "/"+p.ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+			})
+		})
+
+		Convey("When the user is blocked", func() {
+			BlockUser(userTmp.ID, token.UserID, conn)
+
+			postOnWall(token.Hash, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
 	})
 }
 
@@ -589,6 +765,26 @@ func TestShowPost(t *testing.T) {
 			})
 		})
 
+		Convey("When the post has expired but has not been deleted yet", func() {
+			expired := NewPost(PostStatus, user)
+			expired.Text = "An expired post"
+			expired.Expires = float64(time.Now().Unix() - 10)
+			if err := expired.Save(conn); err != nil {
+				panic(err)
+			}
+
+			testGetHandler(ShowPost, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+expired.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 404)
+				So(errResp.Code, ShouldEqual, CodeNotFound)
+			})
+		})
+
 		Convey("When the post can't be accessed by the user", func() {
 			testGetHandler(ShowPost, func(r *http.Request) {
 				r.Header.Add("X-User-Token", tokenTmp.Hash)
@@ -711,3 +907,315 @@ func TestChangePostPrivacy(t *testing.T) {
 		})
 	})
 }
+
+func TestEditPost(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A fancy post"
+	post.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("post_revisions").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Editing a post", t, func() {
+		Convey("When the post does not belong to the user", func() {
+			testPutHandler(EditPost, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+				r.PostForm.Add("post_text", "Edited")
+			}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the status text is empty", func() {
+			testPutHandler(EditPost, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_text", "")
+			}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidStatusText)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			testPutHandler(EditPost, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_text", "An edited fancy post")
+			}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(post.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Text, ShouldEqual, "An edited fancy post")
+			So(p.Edited, ShouldBeTrue)
+			So(p.EditedAt, ShouldBeGreaterThan, 0)
+
+			var revisions []PostRevision
+			err = conn.C("post_revisions").Find(bson.M{"post_id": post.ID}).All(&revisions)
+			So(err, ShouldEqual, nil)
+			So(len(revisions), ShouldEqual, 1)
+			So(revisions[0].Text, ShouldEqual, "A fancy post")
+		})
+
+		Convey("Retrieving the revisions of the post", func() {
+			testGetHandler(GetPostRevisions, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+
+			testGetHandler(GetPostRevisions, func(r *http.Request) {
+				r.Header.Add("X-User-Token", token.Hash)
+			}, conn, "/:id", "/"+post.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["count"], ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestChangePostsPrivacy(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	for i := 0; i < 10; i++ {
+		post := NewPost(PostStatus, user)
+		post.Text = "A fancy post"
+
+		if i < 5 {
+			post.Privacy = PrivacySettings{Type: PrivacyPublic}
+		} else {
+			post.Privacy = PrivacySettings{Type: PrivacyFollowersOnly}
+		}
+
+		if err := post.Save(conn); err != nil {
+			panic(err)
+		}
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("jobs").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Changing privacy settings of all past posts", t, func() {
+		Convey("When invalid privacy type is passed", func() {
+			testPutHandler(ChangePostsPrivacy, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("privacy_type", "20")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidPrivacySettings)
+				So(errResp.Message, ShouldEqual, MsgInvalidPrivacySettings)
+			})
+		})
+
+		Convey("When invalid post type filter is passed", func() {
+			testPutHandler(ChangePostsPrivacy, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("privacy_type", fmt.Sprint(PrivacyNone))
+				r.PostForm.Add("filter_post_type", "poem")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidData)
+				So(errResp.Message, ShouldEqual, MsgInvalidData)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			testPutHandler(ChangePostsPrivacy, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("privacy_type", fmt.Sprint(PrivacyNone))
+				r.PostForm.Add("filter_privacy_type", fmt.Sprint(PrivacyPublic))
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 202)
+			})
+
+			time.Sleep(500 * time.Millisecond)
+
+			count, err := conn.C("posts").Find(bson.M{"privacy.privacy_type": PrivacyNone}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 5)
+
+			var job Job
+			err = conn.C("jobs").Find(bson.M{"user_id": user.ID}).One(&job)
+			So(err, ShouldEqual, nil)
+			So(job.Status, ShouldEqual, JobFinished)
+			So(job.Total, ShouldEqual, 5)
+			So(job.Processed, ShouldEqual, 5)
+			So(job.Failed, ShouldEqual, 0)
+
+			count, err = conn.C("notifications").Find(bson.M{"user_id": user.ID, "job_id": job.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestPostExpiration(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		user.Remove(conn)
+		token.Remove(conn)
+		conn.Session.Close()
+	}()
+
+	Convey("Posting a status that expires", t, func() {
+		Convey("When the expiration is invalid", func() {
+			testPostHandler(CreatePost, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_text", "A test status")
+				r.PostForm.Add("expires_at", "100")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidExpiration)
+				So(errResp.Message, ShouldEqual, MsgInvalidExpiration)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			testPostHandler(CreatePost, func(r *http.Request) {
+				if r.PostForm == nil {
+					r.PostForm = make(url.Values)
+				}
+				r.Header.Add("X-User-Token", token.Hash)
+				r.PostForm.Add("post_text", "A test status")
+				r.PostForm.Add("expires_in", "3600")
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+				So(resp["post"].(map[string]interface{})["expires"].(float64), ShouldBeGreaterThan, float64(time.Now().Unix()))
+			})
+		})
+	})
+}
+
+func TestDeleteExpiredPosts(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	expired := NewPost(PostStatus, user)
+	expired.Text = "An expired post"
+	expired.Expires = float64(time.Now().Unix() - 10)
+	if err := expired.Save(conn); err != nil {
+		panic(err)
+	}
+
+	notExpired := NewPost(PostStatus, user)
+	notExpired.Text = "A post that has not expired yet"
+	notExpired.Expires = float64(time.Now().Unix() + 3600)
+	if err := notExpired.Save(conn); err != nil {
+		panic(err)
+	}
+
+	comment := NewComment(user.ID, expired.ID)
+	comment.Message = "A comment"
+	if err := comment.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Deleting expired posts", t, func() {
+		err := jobs.DeleteExpiredPosts(middleware.Context{Config: config, Conn: conn})
+		So(err, ShouldEqual, nil)
+
+		count, err := conn.C("posts").FindId(expired.ID).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 0)
+
+		count, err = conn.C("comments").Find(bson.M{"post_id": expired.ID}).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 0)
+
+		count, err = conn.C("posts").FindId(notExpired.ID).Count()
+		So(err, ShouldEqual, nil)
+		So(count, ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"fmt"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/mvader/sunglasses/modules/preview"
+	. "github.com/mvader/sunglasses/services"
+	. "github.com/mvader/sunglasses/util"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePreview(t *testing.T) {
+	pageURL, _ := url.Parse("http://example.com/articles/1")
+
+	Convey("Parsing the preview of a page", t, func() {
+		Convey("When the page has OpenGraph tags", func() {
+			p := Parse(strings.NewReader(`<html><head>
+				<title>Document title</title>
+				<meta property="og:title" content="OpenGraph &amp; title">
+				<meta property="og:description" content="OpenGraph description" />
+				<meta property="og:site_name" content="Example">
+				<meta property="og:image" content="/images/cover.jpg">
+				<meta name="twitter:title" content="Twitter title">
+				<link rel="canonical" href="http://example.com/articles/first">
+				</head><body></body></html>`), pageURL)
+
+			So(p.Title, ShouldEqual, "OpenGraph & title")
+			So(p.Description, ShouldEqual, "OpenGraph description")
+			So(p.SiteName, ShouldEqual, "Example")
+			So(p.Image, ShouldEqual, "http://example.com/images/cover.jpg")
+			So(p.CanonicalURL, ShouldEqual, "http://example.com/articles/first")
+		})
+
+		Convey("When the page only has Twitter card tags", func() {
+			p := Parse(strings.NewReader(`<html><head>
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta name="twitter:image" content="javascript:alert(1)">
+				</head></html>`), pageURL)
+
+			So(p.Title, ShouldEqual, "Twitter title")
+			So(p.Description, ShouldEqual, "Twitter description")
+			So(p.Image, ShouldEqual, "")
+			So(p.CanonicalURL, ShouldEqual, "http://example.com/articles/1")
+		})
+
+		Convey("When the page has no metadata", func() {
+			p := Parse(strings.NewReader(`<html><head><title> Document title </title>
+				<meta name="description" content="Page description"></head>
+				<body><meta property="og:title" content="Not in the head"></body></html>`), pageURL)
+
+			So(p.Title, ShouldEqual, "Document title")
+			So(p.Description, ShouldEqual, "Page description")
+		})
+
+		Convey("When the page has no title", func() {
+			p := Parse(strings.NewReader(`not even html`), pageURL)
+
+			So(p.Title, ShouldEqual, "Untitled")
+		})
+	})
+}
+
+func TestFetchPreview(t *testing.T) {
+	defer filepath.Walk("../test_assets/", func(path string, _ os.FileInfo, _ error) error {
+		if path[Strlen(path)-4:] == "jpeg" {
+			os.Remove(path)
+		}
+		return nil
+	})
+
+	config := &Config{
+		StorePath:             "../test_assets/",
+		ThumbnailStorePath:    "../test_assets/",
+		WebStorePath:          "../test_assets/",
+		WebThumbnailStorePath: "../test_assets/",
+		FetchAllowedHosts:     []string{"127.0.0.1"},
+	}
+	fetcher := NewFetcher(config)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><meta property="og:title" content="Gopher">
+			<meta property="og:image" content="%s/gopher.jpg"></head></html>`, server.URL)
+	})
+	mux.HandleFunc("/broken_image", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><meta property="og:image" content="/not_found.jpg"></head></html>`)
+	})
+	mux.HandleFunc("/gopher.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../test_assets/gopher.jpg")
+	})
+
+	Convey("Fetching the preview of a page", t, func() {
+		Convey("When the page does not exist", func() {
+			_, err := Fetch(fetcher, server.URL+"/not_found", config)
+			So(err, ShouldNotEqual, nil)
+		})
+
+		Convey("When the image can't be retrieved", func() {
+			p, err := Fetch(fetcher, server.URL+"/broken_image", config)
+			So(err, ShouldEqual, nil)
+			So(p.Image, ShouldEqual, "")
+			So(p.URL, ShouldEqual, server.URL+"/broken_image")
+		})
+
+		Convey("When everything is OK", func() {
+			p, err := Fetch(fetcher, server.URL+"/page", config)
+			So(err, ShouldEqual, nil)
+			So(p.Title, ShouldEqual, "Gopher")
+			So(p.Image, ShouldNotEqual, "")
+			So(p.ImageThumbnail, ShouldNotEqual, "")
+
+			_, err = os.Stat(p.Image)
+			So(err, ShouldEqual, nil)
+			_, err = os.Stat(p.ImageThumbnail)
+			So(err, ShouldEqual, nil)
+		})
+	})
+}
+
+func TestLinkPreviewCache(t *testing.T) {
+	conn := getConnection()
+	config := &Config{
+		StorePath:             "../test_assets/",
+		ThumbnailStorePath:    "../test_assets/",
+		WebStorePath:          "../test_assets/",
+		WebThumbnailStorePath: "../test_assets/",
+		FetchAllowedHosts:     []string{"127.0.0.1"},
+		LinkPreviewTTLDays:    1,
+	}
+	fetcher := NewFetcher(config)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	defer func() {
+		server.Close()
+		conn.Db.C("link_previews").RemoveAll(nil)
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Session.Close()
+		filepath.Walk("../test_assets/", func(path string, _ os.FileInfo, _ error) error {
+			if path[Strlen(path)-4:] == "jpeg" {
+				os.Remove(path)
+			}
+			return nil
+		})
+	}()
+
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<html><head><meta property="og:title" content="Gopher">
+			<meta property="og:image" content="%s/gopher.jpg"></head></html>`, server.URL)
+	})
+	mux.HandleFunc("/gopher.jpg", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../test_assets/gopher.jpg")
+	})
+
+	expire := func(p *LinkPreview) {
+		p.Fetched = float64(time.Now().Add(-48 * time.Hour).Unix())
+		if err := p.Save(conn); err != nil {
+			panic(err)
+		}
+	}
+
+	Convey("Caching link previews", t, func() {
+		conn.Db.C("link_previews").RemoveAll(nil)
+		conn.Db.C("posts").RemoveAll(nil)
+
+		p, err := Get(conn, config, fetcher, server.URL+"/page")
+		So(err, ShouldEqual, nil)
+
+		Convey("The cached preview is returned while it has not expired", func() {
+			cached, err := Get(conn, config, fetcher, server.URL+"/page")
+			So(err, ShouldEqual, nil)
+			So(cached.ID.Hex(), ShouldEqual, p.ID.Hex())
+		})
+
+		Convey("An expired preview is fetched again", func() {
+			expire(p)
+
+			fetched, err := Get(conn, config, fetcher, server.URL+"/page")
+			So(err, ShouldEqual, nil)
+			So(fetched.ID.Hex(), ShouldNotEqual, p.ID.Hex())
+
+			count, _ := conn.C("link_previews").Find(nil).Count()
+			So(count, ShouldEqual, 1)
+
+			_, err = os.Stat(p.Image)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("Expired previews are removed along with their image", func() {
+			expire(p)
+
+			So(DeleteExpired(conn, config), ShouldEqual, nil)
+
+			count, _ := conn.C("link_previews").Find(nil).Count()
+			So(count, ShouldEqual, 0)
+
+			_, err := os.Stat(p.Image)
+			So(os.IsNotExist(err), ShouldBeTrue)
+			_, err = os.Stat(p.ImageThumbnail)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("The image is kept while a post uses it", func() {
+			post := NewPost(PostLink, NewUser())
+			post.Preview = p
+			if err := post.Save(conn); err != nil {
+				panic(err)
+			}
+
+			expire(p)
+
+			So(DeleteExpired(conn, config), ShouldEqual, nil)
+
+			_, err := os.Stat(p.Image)
+			So(err, ShouldEqual, nil)
+		})
+	})
+}
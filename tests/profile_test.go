@@ -5,6 +5,7 @@ import (
 	. "github.com/mvader/sunglasses/handlers"
 	. "github.com/mvader/sunglasses/models"
 	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -44,6 +45,20 @@ func TestShowUserProfile(t *testing.T) {
 		}
 	}
 
+	scheduled := NewPost(PostStatus, user)
+	scheduled.Text = "A scheduled post"
+	scheduled.Scheduled = float64(time.Now().Unix() + 3600)
+	if err := scheduled.Save(conn); err != nil {
+		panic(err)
+	}
+
+	expired := NewPost(PostStatus, user)
+	expired.Text = "An expired post"
+	expired.Expires = float64(time.Now().Unix() - 10)
+	if err := expired.Save(conn); err != nil {
+		panic(err)
+	}
+
 	defer func() {
 		conn.Db.C("posts").RemoveAll(nil)
 		conn.Db.C("users").RemoveAll(nil)
@@ -62,10 +77,17 @@ func TestShowUserProfile(t *testing.T) {
 				}
 				So(res.Code, ShouldEqual, 200)
 				So(errResp["posts_count"].(float64), ShouldEqual, float64(25))
+				So(errResp["user"].(map[string]interface{})["num_posts"].(float64), ShouldEqual, float64(25))
 				So(errResp["user"].(map[string]interface{})["username"].(string), ShouldNotEqual, "Protected")
+				So(errResp["user"].(map[string]interface{}), ShouldContainKey, "info")
 			})
 		})
 
+		Convey("The info of the user is only included in the profile", func() {
+			users := GetUsersData([]bson.ObjectId{user.ID}, user, conn)
+			So(users[user.ID], ShouldNotContainKey, "info")
+		})
+
 		Convey("When the profile does not exist", func() {
 			testGetHandler(ShowUserProfile, func(r *http.Request) {
 				r.Header.Add("X-User-Token", tokenTmp.Hash)
@@ -6,7 +6,9 @@ import (
 	. "github.com/mvader/sunglasses/handlers"
 	"github.com/mvader/sunglasses/middleware"
 	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
 	"github.com/mvader/sunglasses/modules/timeline"
+	. "github.com/mvader/sunglasses/services"
 	. "github.com/smartystreets/goconvey/convey"
 	"labix.org/v2/mgo/bson"
 	"net/http"
@@ -337,7 +339,7 @@ func TestPropagatePostsOnLike(t *testing.T) {
 		time.Sleep(500 * time.Millisecond)
 
 		err := conn.C("timelines").Find(bson.M{"user_id": userTmp.ID}).One(&t)
-		So(t.Liked, ShouldEqual, false)
+		So(t.Reaction, ShouldEqual, "")
 		So(err, ShouldEqual, nil)
 
 		testHandler(func(m *martini.ClassicMartini) {
@@ -355,7 +357,7 @@ func TestPropagatePostsOnLike(t *testing.T) {
 		time.Sleep(500 * time.Millisecond)
 
 		err = conn.C("timelines").Find(bson.M{"user_id": userTmp.ID}).One(&t)
-		So(t.Liked, ShouldEqual, true)
+		So(t.Reaction, ShouldEqual, ReactionLike)
 		So(err, ShouldEqual, nil)
 	})
 }
@@ -489,6 +491,26 @@ func TestPropagatePostsOnUserDeletion(t *testing.T) {
 			So(res.Code, ShouldEqual, 200)
 		}, true)
 
+		// Timelines are cleared once the deleted account is purged
+		var deleted User
+		if err := conn.C("users").FindId(user.ID).One(&deleted); err != nil {
+			panic(err)
+		}
+
+		config, err := NewConfig("../config.sample.json")
+		if err != nil {
+			panic(err)
+		}
+		config.Debug = false
+
+		ts, err := NewTaskService(config)
+		if err != nil {
+			panic(err)
+		}
+
+		err = jobs.PurgeAccount(middleware.Context{Config: config, Conn: conn, Tasks: ts}, &deleted)
+		So(err, ShouldEqual, nil)
+
 		time.Sleep(500 * time.Millisecond)
 
 		count, err = conn.C("timelines").Find(bson.M{"user_id": userTmp.ID}).Count()
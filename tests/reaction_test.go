@@ -0,0 +1,362 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestReactions(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A post to react to"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	comment := NewComment(user.ID, post.ID)
+	comment.Message = "A comment to react to"
+	if err := comment.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("likes").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	react := func(handler interface{}, route, ID, reaction string, testFunc func(*httptest.ResponseRecorder)) {
+		testPutHandler(handler, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("reaction", reaction)
+		}, conn, route, "/"+ID, testFunc)
+	}
+
+	Convey("Reacting to posts", t, func() {
+		Convey("When the reaction is not valid", func() {
+			react(ReactToPost, "/:id", post.ID.Hex(), "dislike", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidReaction)
+			})
+		})
+
+		Convey("When the post does not exist", func() {
+			react(ReactToPost, "/:id", bson.NewObjectId().Hex(), "love", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 404)
+			})
+		})
+
+		Convey("When the reaction is valid", func() {
+			react(ReactToPost, "/:id", post.ID.Hex(), "love", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["reaction"], ShouldEqual, "love")
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(post.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Likes, ShouldEqual, 1)
+			So(p.Reactions[ReactionLove], ShouldEqual, 1)
+
+			Convey("A different reaction replaces the previous one", func() {
+				react(ReactToPost, "/:id", post.ID.Hex(), "haha", func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				err := conn.C("posts").FindId(post.ID).One(&p)
+				So(err, ShouldEqual, nil)
+				So(p.Likes, ShouldEqual, 1)
+				So(p.Reactions[ReactionLove], ShouldEqual, 0)
+				So(p.Reactions[ReactionHaha], ShouldEqual, 1)
+
+				count, _ := conn.C("likes").Find(bson.M{"post_id": post.ID, "user_id": user.ID}).Count()
+				So(count, ShouldEqual, 1)
+
+				Convey("The same reaction removes it", func() {
+					react(ReactToPost, "/:id", post.ID.Hex(), "haha", func(res *httptest.ResponseRecorder) {
+						var resp map[string]interface{}
+						if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+							panic(err)
+						}
+						So(res.Code, ShouldEqual, 200)
+						So(resp["reaction"], ShouldEqual, "")
+					})
+
+					err := conn.C("posts").FindId(post.ID).One(&p)
+					So(err, ShouldEqual, nil)
+					So(p.Likes, ShouldEqual, 0)
+					So(p.Reactions[ReactionHaha], ShouldEqual, 0)
+				})
+			})
+		})
+	})
+
+	Convey("Reacting to comments", t, func() {
+		react(ReactToComment, "/:comment_id", comment.ID.Hex(), "wow", func(res *httptest.ResponseRecorder) {
+			So(res.Code, ShouldEqual, 200)
+		})
+
+		var cm Comment
+		err := conn.C("comments").FindId(comment.ID).One(&cm)
+		So(err, ShouldEqual, nil)
+		So(cm.Likes, ShouldEqual, 1)
+		So(cm.Reactions[ReactionWow], ShouldEqual, 1)
+
+		var p Post
+		err = conn.C("posts").FindId(post.ID).One(&p)
+		So(err, ShouldEqual, nil)
+		So(p.Likes, ShouldEqual, 0)
+
+		comments := []Comment{*comment}
+		SetCommentReactions(comments, user.ID, conn)
+		So(comments[0].Reaction, ShouldEqual, ReactionWow)
+	})
+
+	Convey("There can only be one reaction of the user to a post", t, func() {
+		conn.Db.C("likes").RemoveAll(nil)
+
+		for _, r := range []ReactionType{ReactionLike, ReactionWow} {
+			err := conn.C("likes").Insert(Reaction{ID: bson.NewObjectId(), UserID: user.ID, PostID: post.ID, Type: r})
+			if r == ReactionLike {
+				So(err, ShouldEqual, nil)
+			} else {
+				So(mgo.IsDup(err), ShouldBeTrue)
+			}
+		}
+	})
+
+	Convey("Reacting to deleted comments", t, func() {
+		deleted := NewComment(user.ID, post.ID)
+		deleted.Deleted = true
+		deleted.Replies = 1
+		if err := deleted.Save(conn); err != nil {
+			panic(err)
+		}
+
+		react(ReactToComment, "/:comment_id", deleted.ID.Hex(), "wow", func(res *httptest.ResponseRecorder) {
+			So(res.Code, ShouldEqual, 404)
+		})
+	})
+}
+
+func TestListPostLikes(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	users := make([]*User, 3)
+	for i := range users {
+		users[i] = NewUser()
+		users[i].Username = "liker_" + strconv.Itoa(i)
+		users[i].Active = true
+		if err := users[i].Save(conn); err != nil {
+			panic(err)
+		}
+	}
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A liked post"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	private := NewPost(PostStatus, users[0])
+	private.Text = "A private post"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	for i, r := range []ReactionType{ReactionLike, ReactionLove, ReactionLike} {
+		if err := conn.C("likes").Insert(Reaction{
+			ID:     bson.NewObjectId(),
+			UserID: users[i].ID,
+			PostID: post.ID,
+			Type:   r,
+		}); err != nil {
+			panic(err)
+		}
+	}
+
+	// The third user has blocked the user
+	if err := BlockUser(users[2].ID, user.ID, conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("likes").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("blocks").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	listLikes := func(postID, reaction string, testFunc func(*httptest.ResponseRecorder)) {
+		testGetHandler(ListPostLikes, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/:id", "/"+postID+"?reaction="+reaction, testFunc)
+	}
+
+	Convey("Listing the users who liked a post", t, func() {
+		Convey("When the user can't access the post", func() {
+			listLikes(private.ID.Hex(), "", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the reaction is not valid", func() {
+			listLikes(post.ID.Hex(), "dislike", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidReaction)
+			})
+		})
+
+		Convey("Blocked users are not listed", func() {
+			listLikes(post.ID.Hex(), "", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["count"], ShouldEqual, 2)
+
+				likes := resp["likes"].([]interface{})
+				So(likes[0].(map[string]interface{})["reaction"], ShouldEqual, "love")
+				So(likes[1].(map[string]interface{})["reaction"], ShouldEqual, "like")
+			})
+		})
+
+		Convey("The users can be filtered by reaction", func() {
+			listLikes(post.ID.Hex(), "love", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				So(resp["count"], ShouldEqual, 1)
+			})
+		})
+	})
+}
+
+func TestCommentLikeNotifications(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, userTmp)
+	post.Text = "A post to react to"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	comment := NewComment(userTmp.ID, post.ID)
+	comment.Message = "A comment to like"
+	if err := comment.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("likes").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	like := func() {
+		conn.Db.C("likes").RemoveAll(nil)
+		if err := comment.Save(conn); err != nil {
+			panic(err)
+		}
+
+		testPutHandler(ReactToComment, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("reaction", "like")
+		}, conn, "/:comment_id", "/"+comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+			So(res.Code, ShouldEqual, 200)
+		})
+	}
+
+	notifications := func() int {
+		count, _ := conn.C("notifications").Find(bson.M{
+			"notification_type": NotificationCommentReacted,
+			"comment_id":        comment.ID,
+		}).Count()
+		return count
+	}
+
+	Convey("Notifying comment likes", t, func() {
+		conn.Db.C("notifications").RemoveAll(nil)
+
+		Convey("When the author has muted the likes in their comments", func() {
+			userTmp.Settings.NotifyLikes = true
+			userTmp.Settings.MuteCommentLikes = true
+			if err := userTmp.Save(conn); err != nil {
+				panic(err)
+			}
+
+			like()
+			So(notifications(), ShouldEqual, 0)
+		})
+
+		Convey("When the author wants to be notified", func() {
+			userTmp.Settings.NotifyLikes = true
+			userTmp.Settings.MuteCommentLikes = false
+			if err := userTmp.Save(conn); err != nil {
+				panic(err)
+			}
+
+			like()
+			So(notifications(), ShouldEqual, 1)
+
+			comments := GetCommentsForPost(post.ID, userTmp, 5, conn)
+			So(len(comments), ShouldEqual, 1)
+			So(comments[0].Likes, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCommentReplies(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	userTmp.Settings.NotifyNewCommentOthers = true
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, user)
+	post.Text = "A fancy post"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	reply := func(parent string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreateComment, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_id", post.ID.Hex())
+			r.PostForm.Add("parent_id", parent)
+			r.PostForm.Add("comment_text", "A fancy reply")
+		}, conn, "/", "/", testFunc)
+	}
+
+	listReplies := func(comment *Comment) []interface{} {
+		var resp map[string]interface{}
+		testGetHandler(CommentReplies, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/:comment_id", "/"+comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+			if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+				panic(err)
+			}
+			So(res.Code, ShouldEqual, 200)
+		})
+
+		return resp["comments"].([]interface{})
+	}
+
+	Convey("Replying to comments", t, func() {
+		conn.Db.C("comments").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+
+		comment := NewComment(userTmp.ID, post.ID)
+		comment.Message = "A fancy comment"
+		if err := comment.Save(conn); err != nil {
+			panic(err)
+		}
+
+		Convey("When the parent comment belongs to another post", func() {
+			other := NewComment(userTmp.ID, bson.NewObjectId())
+			other.Message = "A fancy comment"
+			if err := other.Save(conn); err != nil {
+				panic(err)
+			}
+
+			reply(other.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 404)
+			})
+		})
+
+		Convey("When the reply is valid", func() {
+			var replyID string
+			reply(comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+				So(resp["comment"].(map[string]interface{})["parent_id"], ShouldEqual, comment.ID.Hex())
+				replyID = resp["comment"].(map[string]interface{})["id"].(string)
+			})
+
+			var c Comment
+			So(conn.C("comments").FindId(comment.ID).One(&c), ShouldEqual, nil)
+			So(c.Replies, ShouldEqual, 1)
+
+			count, _ := conn.C("notifications").Find(bson.M{
+				"notification_type": NotificationCommentReplied,
+				"user_id":           userTmp.ID,
+				"comment_id":        comment.ID,
+			}).Count()
+			So(count, ShouldEqual, 1)
+
+			So(len(listReplies(comment)), ShouldEqual, 1)
+
+			Convey("Only top-level comments are listed for the post", func() {
+				comments := GetCommentsForPost(post.ID, user, 5, conn)
+				So(len(comments), ShouldEqual, 1)
+				So(comments[0].ID.Hex(), ShouldEqual, comment.ID.Hex())
+			})
+
+			Convey("Replies can't be nested more than two levels", func() {
+				var nestedID string
+				reply(replyID, func(res *httptest.ResponseRecorder) {
+					var resp map[string]interface{}
+					if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+						panic(err)
+					}
+					So(res.Code, ShouldEqual, 201)
+					nestedID = resp["comment"].(map[string]interface{})["id"].(string)
+				})
+
+				reply(nestedID, func(res *httptest.ResponseRecorder) {
+					var errResp errorResponse
+					if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+						panic(err)
+					}
+					So(res.Code, ShouldEqual, 403)
+					So(errResp.Code, ShouldEqual, CodeCantReply)
+				})
+			})
+
+			Convey("Deleting the parent comment keeps its replies", func() {
+				testDeleteHandler(RemoveComment, func(r *http.Request) {
+					r.Header.Add("X-User-Token", tokenTmp.Hash)
+				}, conn, "/:comment_id", "/"+comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				var c Comment
+				So(conn.C("comments").FindId(comment.ID).One(&c), ShouldEqual, nil)
+				So(c.Deleted, ShouldBeTrue)
+				So(c.Message, ShouldEqual, "")
+				So(len(listReplies(comment)), ShouldEqual, 1)
+
+				Convey("The placeholder can't be deleted again", func() {
+					testDeleteHandler(RemoveComment, func(r *http.Request) {
+						r.Header.Add("X-User-Token", tokenTmp.Hash)
+					}, conn, "/:comment_id", "/"+comment.ID.Hex(), func(res *httptest.ResponseRecorder) {
+						So(res.Code, ShouldEqual, 404)
+					})
+				})
+
+				Convey("The placeholder is removed with its last reply", func() {
+					testDeleteHandler(RemoveComment, func(r *http.Request) {
+						r.Header.Add("X-User-Token", token.Hash)
+					}, conn, "/:comment_id", "/"+replyID, func(res *httptest.ResponseRecorder) {
+						So(res.Code, ShouldEqual, 200)
+					})
+
+					count, _ := conn.C("comments").FindId(comment.ID).Count()
+					So(count, ShouldEqual, 0)
+				})
+			})
+		})
+	})
+}
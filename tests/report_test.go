@@ -0,0 +1,267 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/go-martini/martini"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCreateReport(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, userTmp)
+	post.Text = "A post to report"
+	post.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("reports").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	report := func(reason string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreateReport, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_id", post.ID.Hex())
+			r.PostForm.Add("reason", reason)
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Reporting a post", t, func() {
+		Convey("When the reason is not valid", func() {
+			report("42", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidReportReason)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			report("1", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			count, err := conn.C("reports").Find(bson.M{"post_id": post.ID, "user_id": user.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("When the post has already been reported by the user", func() {
+			report("2", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeAlreadyReported)
+			})
+
+			r := NewReport(user.ID, ReportSpam)
+			r.PostID = post.ID
+			r.AuthorID = userTmp.ID
+			So(mgo.IsDup(r.Save(conn)), ShouldBeTrue)
+		})
+
+		Convey("When the post reaches the reports threshold it is hidden", func() {
+			for i := 1; i < ReportsToHide; i++ {
+				r := NewReport(bson.NewObjectId(), ReportSpam)
+				r.PostID = post.ID
+				r.AuthorID = userTmp.ID
+				if err := r.Save(conn); err != nil {
+					panic(err)
+				}
+			}
+
+			conn.C("reports").Remove(bson.M{"user_id": user.ID})
+			report("1", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").FindId(post.ID).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Hidden, ShouldBeTrue)
+			So((&p).CanBeAccessedBy(user, conn), ShouldBeFalse)
+			So((&p).CanBeAccessedBy(userTmp, conn), ShouldBeTrue)
+		})
+	})
+}
+
+func TestResolveReport(t *testing.T) {
+	conn := getConnection()
+	admin, token := createRequestUser(conn)
+	admin.Role = RoleAdmin
+	if err := admin.Save(conn); err != nil {
+		panic(err)
+	}
+
+	reporter := NewUser()
+	reporter.Username = "reporter"
+	if err := reporter.Save(conn); err != nil {
+		panic(err)
+	}
+
+	author := NewUser()
+	author.Username = "author"
+	if err := author.Save(conn); err != nil {
+		panic(err)
+	}
+
+	authorToken := new(Token)
+	authorToken.Type = UserToken
+	authorToken.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	authorToken.UserID = author.ID
+	if err := authorToken.Save(conn); err != nil {
+		panic(err)
+	}
+
+	post := NewPost(PostStatus, author)
+	post.Text = "A post to report"
+	post.Hidden = true
+	if err := post.Save(conn); err != nil {
+		panic(err)
+	}
+
+	report := NewReport(reporter.ID, ReportSpam)
+	report.PostID = post.ID
+	report.AuthorID = author.ID
+	if err := report.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("reports").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	resolve := func(action string, testFunc func(*httptest.ResponseRecorder)) {
+		testPutHandler(ResolveReport, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("action", action)
+		}, conn, "/:id", "/"+report.ID.Hex(), testFunc)
+	}
+
+	Convey("Resolving a report", t, func() {
+		Convey("When the action is not valid", func() {
+			resolve("ban_forever", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidReportAction)
+			})
+		})
+
+		Convey("When the author is suspended", func() {
+			resolve("suspend", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			var u User
+			err := conn.C("users").FindId(author.ID).One(&u)
+			So(err, ShouldEqual, nil)
+			So(u.Suspended, ShouldBeTrue)
+			So(u.Active, ShouldBeFalse)
+
+			count, err := conn.C("tokens").Find(bson.M{"user_id": author.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 0)
+
+			var r Report
+			err = conn.C("reports").FindId(report.ID).One(&r)
+			So(err, ShouldEqual, nil)
+			So(int(r.Status), ShouldEqual, ReportAuthorSuspended)
+
+			count, err = conn.C("notifications").Find(bson.M{"user_id": reporter.ID, "report_id": report.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("When the report has already been resolved", func() {
+			resolve("dismiss", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 404)
+			})
+		})
+	})
+}
+
+func TestReportQueueAccess(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	defer func() {
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	listReports := func(role UserRole, testFunc func(*httptest.ResponseRecorder)) {
+		user.Role = role
+		if err := user.Save(conn); err != nil {
+			panic(err)
+		}
+
+		testHandler(func(m *martini.ClassicMartini) {
+			m.Get("/", middleware.ModeratorRequired, ListReports)
+		}, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/", "GET", testFunc, false)
+	}
+
+	Convey("Accessing the report queue", t, func() {
+		Convey("When the user is not a moderator", func() {
+			listReports(RoleUser, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 403)
+			})
+		})
+
+		Convey("When the user is a moderator", func() {
+			listReports(RoleModerator, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+		})
+
+		Convey("When the user is an admin", func() {
+			listReports(RoleAdmin, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+		})
+	})
+}
@@ -0,0 +1,224 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestResharePost(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	public := NewPost(PostStatus, userTmp)
+	public.Text = "A public post"
+	public.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := public.Save(conn); err != nil {
+		panic(err)
+	}
+
+	private := NewPost(PostStatus, userTmp)
+	private.Text = "A private post"
+	private.Privacy = PrivacySettings{Type: PrivacyNone}
+	if err := private.Save(conn); err != nil {
+		panic(err)
+	}
+
+	// user follows userTmp so the followers-only post can be accessed
+	followersOnly := NewPost(PostStatus, userTmp)
+	followersOnly.Text = "A post for followers"
+	followersOnly.Privacy = PrivacySettings{Type: PrivacyFollowersOnly}
+	if err := followersOnly.Save(conn); err != nil {
+		panic(err)
+	}
+
+	if err := FollowUser(user.ID, userTmp.ID, conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Db.C("follows").RemoveAll(nil)
+		conn.Db.C("notifications").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	reshare := func(postID, privacy string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_type", "reshare")
+			r.PostForm.Add("post_id", postID)
+			r.PostForm.Add("privacy_type", privacy)
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Resharing posts", t, func() {
+		Convey("When the post can't be accessed by the user", func() {
+			reshare(private.ID.Hex(), "1", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 403)
+				So(errResp.Code, ShouldEqual, CodeUnauthorized)
+			})
+		})
+
+		Convey("When the audience of the reshare is wider than the audience of the post", func() {
+			reshare(followersOnly.ID.Hex(), "1", func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 403)
+				So(errResp.Code, ShouldEqual, CodeCantReshare)
+				So(errResp.Message, ShouldEqual, MsgCantReshare)
+			})
+		})
+
+		Convey("When the post is public", func() {
+			reshare(public.ID.Hex(), "1", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"reshare_of": public.ID}).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.UserID.Hex(), ShouldEqual, user.ID.Hex())
+
+			count, err := conn.C("notifications").Find(bson.M{
+				"user_id":           userTmp.ID,
+				"post_id":           public.ID,
+				"notification_type": NotificationPostReshared,
+			}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 1)
+
+			Convey("When the privacy of the post is narrowed the reshare is removed", func() {
+				testPutHandler(ChangePostPrivacy, func(r *http.Request) {
+					if r.PostForm == nil {
+						r.PostForm = make(url.Values)
+					}
+					r.Header.Add("X-User-Token", tokenTmp.Hash)
+					r.PostForm.Add("privacy_type", "4")
+				}, conn, "/:id", "/"+public.ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				count, err := conn.C("posts").Find(bson.M{"reshare_of": public.ID}).Count()
+				So(err, ShouldEqual, nil)
+				So(count, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the original post is deleted", func() {
+			reshare(followersOnly.ID.Hex(), "4", func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			testDeleteHandler(DeletePost, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokenTmp.Hash)
+			}, conn, "/:id", "/"+followersOnly.ID.Hex(), func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 200)
+			})
+
+			count, err := conn.C("posts").Find(bson.M{"reshare_of": followersOnly.ID}).Count()
+			So(err, ShouldEqual, nil)
+			So(count, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestSetReshareOriginals(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	original := NewPost(PostStatus, userTmp)
+	original.Text = "A public post"
+	original.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := original.Save(conn); err != nil {
+		panic(err)
+	}
+
+	reshare := NewPost(PostReshare, user)
+	reshare.ReshareOf = original.ID
+	reshare.Privacy = PrivacySettings{Type: PrivacyPublic}
+	if err := reshare.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	setOriginal := func(viewer *User) *Post {
+		posts := []Post{*reshare}
+		SetReshareOriginals(posts, viewer, conn)
+		return posts[0].Original
+	}
+
+	Convey("Setting the originals of reshares", t, func() {
+		original.Hidden = false
+		original.Expires = 0
+		if err := original.Save(conn); err != nil {
+			panic(err)
+		}
+
+		So(setOriginal(user), ShouldNotBeNil)
+
+		Convey("When the original has been hidden", func() {
+			original.Hidden = true
+			if err := original.Save(conn); err != nil {
+				panic(err)
+			}
+
+			So(setOriginal(user), ShouldBeNil)
+			So(setOriginal(userTmp), ShouldNotBeNil)
+		})
+
+		Convey("When the original has expired", func() {
+			original.Expires = float64(time.Now().Unix() - 10)
+			if err := original.Save(conn); err != nil {
+				panic(err)
+			}
+
+			So(setOriginal(user), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,233 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	"github.com/mvader/sunglasses/middleware"
+	. "github.com/mvader/sunglasses/models"
+	"github.com/mvader/sunglasses/modules/jobs"
+	"github.com/mvader/sunglasses/services"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestScheduledPosts(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	post := func(publishAt int64, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_text", "A scheduled post")
+			r.PostForm.Add("publish_at", fmt.Sprint(publishAt))
+			r.PostForm.Add("expires_in", "3600")
+		}, conn, "/", "/", testFunc)
+	}
+
+	Convey("Scheduling posts", t, func() {
+		Convey("When the publish time has already passed", func() {
+			post(time.Now().Unix()-10, func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidPublishTime)
+				So(errResp.Message, ShouldEqual, MsgInvalidPublishTime)
+			})
+		})
+
+		Convey("When everything is OK", func() {
+			publishAt := time.Now().Unix() + 3600
+			post(publishAt, func(res *httptest.ResponseRecorder) {
+				So(res.Code, ShouldEqual, 201)
+			})
+
+			var p Post
+			err := conn.C("posts").Find(bson.M{"user_id": user.ID}).One(&p)
+			So(err, ShouldEqual, nil)
+			So(p.Scheduled, ShouldEqual, float64(publishAt))
+			So(p.Expires, ShouldEqual, float64(publishAt+3600))
+
+			Convey("The post can't be seen until it's published", func() {
+				testGetHandler(ShowPost, func(r *http.Request) {
+					r.Header.Add("X-User-Token", token.Hash)
+				}, conn, "/:id", "/"+p.ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 404)
+				})
+			})
+
+			Convey("The post is listed among the scheduled posts of the user", func() {
+				testGetHandler(ListScheduledPosts, func(r *http.Request) {
+					r.Header.Add("X-User-Token", token.Hash)
+				}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+					var resp struct {
+						Posts []Post `json:"posts"`
+					}
+					if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+						panic(err)
+					}
+
+					So(res.Code, ShouldEqual, 200)
+					So(len(resp.Posts), ShouldEqual, 1)
+					So(resp.Posts[0].ID.Hex(), ShouldEqual, p.ID.Hex())
+				})
+			})
+
+			Convey("The post can be rescheduled", func() {
+				testPutHandler(ReschedulePost, func(r *http.Request) {
+					if r.PostForm == nil {
+						r.PostForm = make(url.Values)
+					}
+					r.Header.Add("X-User-Token", token.Hash)
+					r.PostForm.Add("publish_at", fmt.Sprint(publishAt+60))
+				}, conn, "/:id", "/"+p.ID.Hex(), func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				err := conn.C("posts").FindId(p.ID).One(&p)
+				So(err, ShouldEqual, nil)
+				So(p.Scheduled, ShouldEqual, float64(publishAt+60))
+				So(p.Expires, ShouldEqual, float64(publishAt+3660))
+			})
+		})
+	})
+}
+
+func TestPublishScheduledPosts(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	due := NewPost(PostStatus, user)
+	due.Text = "A post that has to be published"
+	due.Scheduled = float64(time.Now().Unix() - 10)
+	if err := due.Save(conn); err != nil {
+		panic(err)
+	}
+
+	notDue := NewPost(PostStatus, user)
+	notDue.Text = "A post that has not to be published yet"
+	notDue.Scheduled = float64(time.Now().Unix() + 3600)
+	if err := notDue.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Publishing scheduled posts", t, func() {
+		err := jobs.PublishScheduledPosts(middleware.Context{Config: config, Conn: conn})
+		So(err, ShouldEqual, nil)
+
+		var p Post
+		err = conn.C("posts").FindId(due.ID).One(&p)
+		So(err, ShouldEqual, nil)
+		So(p.Scheduled, ShouldEqual, 0)
+		So(p.Created, ShouldEqual, due.Scheduled)
+
+		err = conn.C("posts").FindId(notDue.ID).One(&p)
+		So(err, ShouldEqual, nil)
+		So(p.Scheduled, ShouldEqual, notDue.Scheduled)
+	})
+}
+
+func TestDeleteScheduledReshare(t *testing.T) {
+	conn := getConnection()
+	user, _ := createRequestUser(conn)
+	config, err := services.NewConfig("../config.sample.json")
+	if err != nil {
+		panic(err)
+	}
+
+	original := NewPost(PostStatus, user)
+	original.Text = "A post to reshare"
+	original.Reshares = 1
+	if err := original.Save(conn); err != nil {
+		panic(err)
+	}
+
+	reshare := NewPost(PostReshare, user)
+	reshare.ReshareOf = original.ID
+	reshare.Scheduled = float64(time.Now().Unix() + 3600)
+	if err := reshare.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Cancelling a scheduled reshare doesn't change the reshares of the original", t, func() {
+		err := jobs.DeletePost(middleware.Context{Config: config, Conn: conn}, reshare)
+		So(err, ShouldEqual, nil)
+
+		var p Post
+		So(conn.C("posts").FindId(original.ID).One(&p), ShouldEqual, nil)
+		So(p.Reshares, ShouldEqual, 1)
+	})
+}
+
+func TestRescheduledPoll(t *testing.T) {
+	conn := getConnection()
+	user, token := createRequestUser(conn)
+
+	publishAt := float64(time.Now().Unix() + 3600)
+	poll := NewPost(PostPoll, user)
+	poll.Text = "Which one?"
+	poll.Scheduled = publishAt
+	poll.Poll = NewPoll([]string{"One", "Two"}, false, publishAt+DefaultPollDuration)
+	if err := poll.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	Convey("Rescheduling a poll moves its closing time", t, func() {
+		testPutHandler(ReschedulePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("publish_at", fmt.Sprint(int64(publishAt)+2*DefaultPollDuration))
+		}, conn, "/:id", "/"+poll.ID.Hex(), func(res *httptest.ResponseRecorder) {
+			So(res.Code, ShouldEqual, 200)
+		})
+
+		var p Post
+		So(conn.C("posts").FindId(poll.ID).One(&p), ShouldEqual, nil)
+		So(p.Poll.Closes, ShouldEqual, publishAt+3*DefaultPollDuration)
+	})
+}
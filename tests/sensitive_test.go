@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"encoding/json"
+	. "github.com/mvader/sunglasses/error"
+	. "github.com/mvader/sunglasses/handlers"
+	. "github.com/mvader/sunglasses/models"
+	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSensitivePosts(t *testing.T) {
+	conn := getConnection()
+	_, token := createRequestUser(conn)
+
+	userTmp := NewUser()
+	userTmp.Username = "testing_very_hard"
+	if err := userTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	tokenTmp := new(Token)
+	tokenTmp.Type = UserToken
+	tokenTmp.Expires = float64(time.Now().Unix() + int64(3600*time.Second))
+	tokenTmp.UserID = userTmp.ID
+	if err := tokenTmp.Save(conn); err != nil {
+		panic(err)
+	}
+
+	defer func() {
+		conn.Db.C("posts").RemoveAll(nil)
+		conn.Db.C("users").RemoveAll(nil)
+		conn.Db.C("tokens").RemoveAll(nil)
+		conn.Session.Close()
+	}()
+
+	createPost := func(warning string, testFunc func(*httptest.ResponseRecorder)) {
+		testPostHandler(CreatePost, func(r *http.Request) {
+			if r.PostForm == nil {
+				r.PostForm = make(url.Values)
+			}
+			r.Header.Add("X-User-Token", token.Hash)
+			r.PostForm.Add("post_text", "A sensitive post")
+			r.PostForm.Add("privacy_type", "1")
+			r.PostForm.Add("content_warning", warning)
+			r.PostForm.Add("sensitive", "true")
+		}, conn, "/", "/", testFunc)
+	}
+
+	showPost := func(token *Token, post string) map[string]interface{} {
+		var resp map[string]interface{}
+		testGetHandler(ShowPost, func(r *http.Request) {
+			r.Header.Add("X-User-Token", token.Hash)
+		}, conn, "/:id", "/"+post, func(res *httptest.ResponseRecorder) {
+			if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+				panic(err)
+			}
+			So(res.Code, ShouldEqual, 200)
+		})
+
+		return resp["post"].(map[string]interface{})
+	}
+
+	Convey("Marking posts as sensitive", t, func() {
+		Convey("When the content warning is too long", func() {
+			createPost(strings.Repeat("a", 101), func(res *httptest.ResponseRecorder) {
+				var errResp errorResponse
+				if err := json.Unmarshal(res.Body.Bytes(), &errResp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 400)
+				So(errResp.Code, ShouldEqual, CodeInvalidContentWarning)
+			})
+		})
+
+		Convey("When the content warning is valid", func() {
+			var post string
+			createPost("Spoilers", func(res *httptest.ResponseRecorder) {
+				var resp map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &resp); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 201)
+				post = resp["post"].(map[string]interface{})["id"].(string)
+			})
+
+			Convey("The post is collapsed for other users", func() {
+				p := showPost(tokenTmp, post)
+				So(p["content_warning"], ShouldEqual, "Spoilers")
+				So(p["sensitive"], ShouldEqual, true)
+				So(p["collapsed"], ShouldEqual, true)
+			})
+
+			Convey("The post is not collapsed for its author", func() {
+				p := showPost(token, post)
+				So(p["collapsed"], ShouldEqual, false)
+			})
+
+			Convey("The post is not collapsed for users who expand sensitive content", func() {
+				userTmp.Settings.ExpandSensitiveContent = true
+				if err := userTmp.Save(conn); err != nil {
+					panic(err)
+				}
+
+				p := showPost(tokenTmp, post)
+				So(p["collapsed"], ShouldEqual, false)
+			})
+
+			Convey("The content warning can be edited", func() {
+				testPutHandler(EditPost, func(r *http.Request) {
+					if r.PostForm == nil {
+						r.PostForm = make(url.Values)
+					}
+					r.Header.Add("X-User-Token", token.Hash)
+					r.PostForm.Add("content_warning", "")
+					r.PostForm.Add("sensitive", "false")
+				}, conn, "/:id", "/"+post, func(res *httptest.ResponseRecorder) {
+					So(res.Code, ShouldEqual, 200)
+				})
+
+				var p Post
+				err := conn.C("posts").FindId(bson.ObjectIdHex(post)).One(&p)
+				So(err, ShouldEqual, nil)
+				So(p.ContentWarning, ShouldEqual, "")
+				So(p.Sensitive, ShouldBeFalse)
+			})
+		})
+	})
+}
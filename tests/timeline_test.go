@@ -7,6 +7,7 @@ import (
 	"github.com/mvader/sunglasses/handlers"
 	. "github.com/mvader/sunglasses/models"
 	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -162,4 +163,27 @@ func TestGetUserTimeline(t *testing.T) {
 			})
 		})
 	}
+
+	Convey("Hidden posts are only in the timeline of their author", t, func() {
+		if err := conn.C("posts").UpdateId(bson.ObjectIdHex(posts[0][0]), bson.M{"$set": bson.M{"hidden": true}}); err != nil {
+			panic(err)
+		}
+
+		for _, i := range []int{0, 1} {
+			testGetHandler(handlers.GetUserTimeline, func(r *http.Request) {
+				r.Header.Add("X-User-Token", tokens[i].Hash)
+			}, conn, "/", "/", func(res *httptest.ResponseRecorder) {
+				var result map[string]interface{}
+				if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+					panic(err)
+				}
+				So(res.Code, ShouldEqual, 200)
+				if i == 0 {
+					So(result["count"].(float64), ShouldEqual, resultCounts[0])
+				} else {
+					So(result["count"].(float64), ShouldEqual, resultCounts[1]-1)
+				}
+			})
+		}
+	})
 }
@@ -3,6 +3,7 @@ package tests
 import (
 	. "github.com/mvader/sunglasses/models"
 	. "github.com/smartystreets/goconvey/convey"
+	"labix.org/v2/mgo/bson"
 	"testing"
 )
 
@@ -56,3 +57,41 @@ func TestUser(t *testing.T) {
 		})
 	})
 }
+
+func TestUserInfoForUser(t *testing.T) {
+	Convey("Subject: Filtering the user info for another user", t, func() {
+		user := NewUser()
+		user.ID = bson.NewObjectId()
+		user.Settings.DisplayInfoFollowersOnly = true
+		user.Info.Work = "20th Century Fox"
+		user.Info.Education = "Harvard"
+		user.Info.About = "About me"
+		user.Info.Privacy = map[string]PrivacySettings{
+			"work":  PrivacySettings{Type: PrivacyPublic},
+			"about": PrivacySettings{Type: PrivacyNone},
+		}
+		viewer := bson.NewObjectId()
+
+		Convey("The owner must see all the fields and their privacy", func() {
+			info := user.InfoForUser(user.ID, false, false)
+			So(info.Work, ShouldEqual, "20th Century Fox")
+			So(info.About, ShouldEqual, "About me")
+			So(info.Privacy["education"].Type, ShouldEqual, PrivacyFollowersOnly)
+		})
+
+		Convey("A follower must see all the fields but the private ones", func() {
+			info := user.InfoForUser(viewer, true, false)
+			So(info.Work, ShouldEqual, "20th Century Fox")
+			So(info.Education, ShouldEqual, "Harvard")
+			So(info.About, ShouldEqual, "")
+			So(info.Privacy, ShouldBeNil)
+		})
+
+		Convey("Any other user must see just the public fields", func() {
+			info := user.InfoForUser(viewer, false, false)
+			So(info.Work, ShouldEqual, "20th Century Fox")
+			So(info.Education, ShouldEqual, "")
+			So(info.About, ShouldEqual, "")
+		})
+	})
+}
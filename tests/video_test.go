@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/mvader/sunglasses/models"
+	. "github.com/mvader/sunglasses/modules/video"
+	. "github.com/mvader/sunglasses/services"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVideoRegistry(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("url") != server.URL+"/videos/watch/abc-123" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprintf(w, `{"title": "A video", "thumbnail_url": "%s/thumb.jpg", "duration": 42}`, server.URL)
+	})
+
+	config := &Config{
+		FetchAllowedHosts: []string{"127.0.0.1"},
+		VideoProviders: []VideoProviderConfig{
+			VideoProviderConfig{
+				Name:           "peertube",
+				URLPatterns:    []string{"^" + server.URL + "/videos/watch/([a-z0-9-]+)"},
+				OEmbedEndpoint: server.URL + "/oembed",
+				EmbedURL:       server.URL + "/videos/embed/{id}",
+			},
+		},
+	}
+	fetcher := NewFetcher(config)
+
+	Convey("Retrieving videos from providers", t, func() {
+		registry, err := NewRegistry(config)
+		So(err, ShouldEqual, nil)
+
+		Convey("When the URL belongs to a built-in provider", func() {
+			p, ID, ok := registry.Find("https://youtu.be/9bZkp7q19f0")
+			So(ok, ShouldBeTrue)
+			So(p.Name(), ShouldEqual, "youtube")
+			So(ID, ShouldEqual, "9bZkp7q19f0")
+
+			p, ID, ok = registry.Find("http://vimeo.com/89856635")
+			So(ok, ShouldBeTrue)
+			So(int(p.Service()), ShouldEqual, models.VideoServiceVimeo)
+			So(ID, ShouldEqual, "89856635")
+		})
+
+		Convey("When no provider supports the URL", func() {
+			_, err := registry.Video(fetcher, "http://example.com/video/1")
+			So(err, ShouldEqual, ErrUnsupportedVideo)
+		})
+
+		Convey("When the video does not exist", func() {
+			_, err := registry.Video(fetcher, server.URL+"/videos/watch/not-found")
+			So(err, ShouldEqual, ErrVideoNotFound)
+		})
+
+		Convey("When the video belongs to a configured provider", func() {
+			v, err := registry.Video(fetcher, server.URL+"/videos/watch/abc-123")
+			So(err, ShouldEqual, nil)
+			So(v.Provider, ShouldEqual, "peertube")
+			So(v.ID, ShouldEqual, "abc-123")
+			So(v.Title, ShouldEqual, "A video")
+			So(v.ThumbnailURL, ShouldEqual, server.URL+"/thumb.jpg")
+			So(v.Duration, ShouldEqual, 42)
+			So(v.EmbedURL, ShouldEqual, server.URL+"/videos/embed/abc-123")
+		})
+
+		Convey("When a configured provider is not valid", func() {
+			_, err := NewRegistry(&Config{
+				VideoProviders: []VideoProviderConfig{
+					VideoProviderConfig{
+						Name:           "invalid",
+						URLPatterns:    []string{"^https://example.com/videos/"},
+						OEmbedEndpoint: "https://example.com/oembed",
+						EmbedURL:       "https://example.com/embed/{id}",
+					},
+				},
+			})
+			So(err, ShouldNotEqual, nil)
+		})
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha512"
 	"encoding/base64"
 	"fmt"
+	"github.com/mvader/sunglasses/services/interfaces"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -74,13 +75,16 @@ func IsValidURL(URL string) bool {
 	return r.MatchString(URL)
 }
 
+// MaxResponseSize is the maximum number of bytes read from the body of a response
+const MaxResponseSize = 512 << 10
+
 func ResponseTitle(resp *http.Response) string {
 	var title string
 
 	r := regexp.MustCompile("<title>(.*)</title>")
 	defer resp.Body.Close()
 
-	bytes, err := ioutil.ReadAll(resp.Body)
+	bytes, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxResponseSize))
 	if err != nil {
 		return ""
 	}
@@ -95,8 +99,8 @@ func ResponseTitle(resp *http.Response) string {
 	return title
 }
 
-func IsValidLink(URL string) (bool, string, string) {
-	resp, err := http.Get(URL)
+func IsValidLink(fetcher interfaces.Fetcher, URL string) (bool, string, string) {
+	resp, err := fetcher.Get(URL)
 	if err != nil || resp.StatusCode != 200 {
 		return false, "", ""
 	}